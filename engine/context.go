@@ -11,5 +11,20 @@ func NewContext(configFile string) *Context {
 
 	instance.Configuration = config
 	instance.Engine = NewEngine()
+	instance.Engine.Lang = config.String("lang", instance.Engine.Lang)
+	instance.Engine.Path = config.String("path", instance.Engine.Path)
+	instance.Engine.Level = config.String("level", instance.Engine.Level)
+	instance.Engine.UserDictionary = config.String("dictionary.user", instance.Engine.UserDictionary)
+	instance.Engine.Workers = int(config.Int64("workers", int64(instance.Engine.Workers)))
+
+	crawler := instance.Engine.Crawler
+	crawler.SetTimeout(config.Int64("crawler.timeout", crawler.GetTimeout()))
+	crawler.SetUserAgent(config.String("crawler.user_agent", crawler.GetUserAgent()))
+	crawler.SetMaxRedirects(int(config.Int64("crawler.max_redirects", int64(crawler.GetMaxRedirects()))))
+	crawler.SetMaxSize(config.Int64("crawler.max_size", crawler.GetMaxSize()))
+	crawler.SetRobots(config.Bool("crawler.robots", crawler.GetRobots()))
+	crawler.SetPages(int(config.Int64("crawler.pages", int64(crawler.GetPages()))))
+	crawler.SetLevels(int(config.Int64("crawler.levels", int64(crawler.GetLevels()))))
+	crawler.SetDelay(config.Int64("crawler.delay", crawler.GetDelay()))
 	return instance
 }
@@ -1,32 +1,88 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/cheggaaa/pb"
 
+	"github.com/advancedlogic/go-freeling/models"
 	"github.com/advancedlogic/go-freeling/nlp"
 	. "github.com/advancedlogic/go-freeling/terminal"
 	"github.com/advancedlogic/go-freeling/wordnet"
+	"github.com/advancedlogic/go-freeling/writer"
 )
 
+// VALIDATION_TEXT is analyzed by a reloaded engine before it is used.
+const VALIDATION_TEXT = "This is a test. It checks that the data was loaded."
+
 type Engine struct {
 	semaphore *sync.Mutex
-	NLP       *nlp.NLPEngine
-	Ready     bool
+	// the engine loaded by InitNLP and replaced by Reload, kept for
+	// compatibility: Current, or Acquire for a whole request, are safe
+	// while reloading
+	NLP     *nlp.NLPEngine
+	lock    sync.RWMutex
+	current *instance
+	Ready   bool
+	Lang    string
+	Path    string
+	Level   string
+	// user dictionary file, data/<lang>/userdicc.src when empty
+	UserDictionary string
+	// sentences of a document analyzed in parallel, the number of CPUs when 0
+	Workers int
+	// fetches the pages of url documents, set from the [crawler] section
+	Crawler *nlp.Crawler
+}
+
+// instance is an NLP engine with the requests in flight on it.
+type instance struct {
+	nlp      *nlp.NLPEngine
+	requests sync.WaitGroup
 }
 
 func NewEngine() *Engine {
 	return &Engine{
 		semaphore: new(sync.Mutex),
 		Ready:     false,
+		Lang:      "en",
+		Path:      "./",
+		Crawler:   nlp.NewDefaultCrawler(),
 	}
 }
 
-var path = "./"
-var lang = "en"
+// Pipeline stop levels, in processing order. An empty level runs the whole
+// pipeline.
+var levels = []string{
+	writer.LEVEL_TOKEN,
+	writer.LEVEL_SPLITTED,
+	writer.LEVEL_MORFO,
+	writer.LEVEL_TAGGED,
+	writer.LEVEL_SHALLOW,
+	writer.LEVEL_SENSE,
+}
+
+// reaches tells whether the pipeline runs up to the given level.
+func (e *Engine) reaches(level string) bool {
+	if e.Level == "" {
+		return true
+	}
+	stop, target := -1, -1
+	for i, l := range levels {
+		if l == e.Level {
+			stop = i
+		}
+		if l == level {
+			target = i
+		}
+	}
+	return stop < 0 || target <= stop
+}
 
 func (e *Engine) InitNLP() {
 	e.semaphore.Lock()
@@ -37,7 +93,9 @@ func (e *Engine) InitNLP() {
 	Infoln("Init Natural Language Processing Engine")
 	initialized := false
 	count := 80
-	bar := pb.StartNew(count)
+	bar := pb.New(count)
+	bar.Output = Writer()
+	bar.Start()
 	bar.ShowPercent = true
 	bar.ShowCounters = false
 
@@ -48,22 +106,7 @@ func (e *Engine) InitNLP() {
 	}
 
 	start := time.Now().UnixNano()
-	nlpOptions := nlp.NewNLPOptions(path+"data/", lang, inc)
-	nlpOptions.Severity = nlp.ERROR
-	nlpOptions.TokenizerFile = "tokenizer.dat"
-	nlpOptions.SplitterFile = "splitter.dat"
-	nlpOptions.TaggerFile = "tagger.dat"
-	nlpOptions.ShallowParserFile = "chunker/grammar-chunk.dat"
-	nlpOptions.SenseFile = "senses.dat"
-	nlpOptions.UKBFile = "" //"ukb.dat"
-	nlpOptions.DisambiguatorFile = "common/knowledge.dat"
-
-	macoOptions := nlp.NewMacoOptions(lang)
-	macoOptions.SetDataFiles("", path+"data/common/punct.dat", path+"data/"+lang+"/dicc.src", "", "", path+"data/"+lang+"/locucions-extended.dat", path+"data/"+lang+"/np.dat", "", path+"data/"+lang+"/probabilitats.dat")
-
-	nlpOptions.MorfoOptions = macoOptions
-
-	nlpEngine := nlp.NewNLPEngine(nlpOptions)
+	nlpEngine := nlp.NewNLPEngine(e.options(inc))
 
 	stop := time.Now().UnixNano()
 	delta := (stop - start) / (1000 * 1000)
@@ -73,6 +116,163 @@ func (e *Engine) InitNLP() {
 	wn := wordnet.NewWordNet()
 	nlpEngine.WordNet = wn
 
+	e.lock.Lock()
+	e.current = &instance{nlp: nlpEngine}
 	e.NLP = nlpEngine
+	e.lock.Unlock()
 	e.Ready = initialized
 }
+
+// Reload loads the linguistic data again into a new NLP engine and, once it
+// analyzes a sample text, swaps it for the current one. Requests in flight
+// finish on the previous engine, which is released afterwards. When loading
+// or validating fails the current engine is kept.
+func (e *Engine) Reload() error {
+	e.semaphore.Lock()
+	defer e.semaphore.Unlock()
+	if !e.Ready {
+		return errors.New("engine not initialized")
+	}
+	Infoln("Reloading Natural Language Processing Engine")
+
+	start := time.Now().UnixNano()
+	nlpEngine, err := e.build()
+	if err == nil {
+		nlpEngine.WordNet = e.Current().WordNet
+		if err = validate(nlpEngine); err != nil {
+			nlpEngine.Release()
+		}
+	}
+	if err != nil {
+		Errorln("Reload failed, keeping the current engine: " + err.Error())
+		return err
+	}
+
+	e.lock.Lock()
+	previous := e.current
+	e.current = &instance{nlp: nlpEngine}
+	e.NLP = nlpEngine
+	e.lock.Unlock()
+	go func() {
+		previous.requests.Wait()
+		previous.nlp.Release()
+	}()
+
+	Infof("Data reloaded in %dms\n", (time.Now().UnixNano()-start)/(1000*1000))
+	return nil
+}
+
+// AddLexiconEntry adds an entry to the user dictionary of the current engine.
+// Edits are serialized with Reload, so an entry is never added to an engine
+// being replaced after its replacement has read the user dictionary.
+func (e *Engine) AddLexiconEntry(form string, lemma string, tag string) (bool, error) {
+	e.semaphore.Lock()
+	defer e.semaphore.Unlock()
+	dictionary := e.Current().Dictionary()
+	if dictionary == nil {
+		return false, nlp.ErrNoDictionary
+	}
+	return dictionary.AddEntry(form, lemma, tag)
+}
+
+// RemoveLexiconEntry removes entries from the user dictionary of the current
+// engine, serialized with Reload as AddLexiconEntry.
+func (e *Engine) RemoveLexiconEntry(form string, lemma string, tag string) (int, error) {
+	e.semaphore.Lock()
+	defer e.semaphore.Unlock()
+	dictionary := e.Current().Dictionary()
+	if dictionary == nil {
+		return 0, nlp.ErrNoDictionary
+	}
+	return dictionary.RemoveEntry(form, lemma, tag)
+}
+
+// Current returns the current NLP engine.
+func (e *Engine) Current() *nlp.NLPEngine {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	if e.current == nil {
+		return nil
+	}
+	return e.current.nlp
+}
+
+// Acquire returns the current NLP engine for a request, and the function to
+// call when the request is done with it.
+func (e *Engine) Acquire() (*nlp.NLPEngine, func()) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	current := e.current
+	current.requests.Add(1)
+	return current.nlp, current.requests.Done
+}
+
+// build loads a new NLP engine, turning the panics of the loaders into an
+// error.
+func (e *Engine) build() (nlpEngine *nlp.NLPEngine, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return nlp.NewNLPEngine(e.options(func() {})), nil
+}
+
+// validate analyzes a sample text with a new NLP engine.
+func validate(nlpEngine *nlp.NLPEngine) error {
+	document := models.NewDocumentEntity()
+	document.Content = VALIDATION_TEXT
+	output := make(chan *models.DocumentEntity, 1)
+	nlpEngine.Workflow(document, output)
+	if <-output == nil {
+		return errors.New("analysis of a sample text failed")
+	}
+	return nil
+}
+
+func (e *Engine) options(inc func()) *nlp.NLPOptions {
+	path := e.Path
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	lang := e.Lang
+
+	nlpOptions := nlp.NewNLPOptions(path+"data/", lang, inc)
+	nlpOptions.Severity = nlp.ERROR
+	if e.Workers > 0 {
+		nlpOptions.Workers = e.Workers
+	}
+	nlpOptions.Crawler = e.Crawler
+	nlpOptions.TokenizerFile = "tokenizer.dat"
+	nlpOptions.SplitterFile = "splitter.dat"
+	if e.reaches(writer.LEVEL_TAGGED) {
+		nlpOptions.TaggerFile = "tagger.dat"
+		nlpOptions.TagsetFile = "tagset.dat"
+	}
+	if e.reaches(writer.LEVEL_SHALLOW) {
+		nlpOptions.ShallowParserFile = "chunker/grammar-chunk.dat"
+	}
+	if e.reaches(writer.LEVEL_SENSE) {
+		nlpOptions.SenseFile = "senses.dat"
+		nlpOptions.UKBFile = "" //"ukb.dat"
+		nlpOptions.DisambiguatorFile = "common/knowledge.dat"
+	}
+
+	if e.reaches(writer.LEVEL_MORFO) {
+		macoOptions := nlp.NewMacoOptions(lang)
+		macoOptions.SetDataFiles("", path+"data/common/punct.dat", path+"data/"+lang+"/dicc.src", "", "", path+"data/"+lang+"/locucions-extended.dat", path+"data/"+lang+"/np.dat", "", path+"data/"+lang+"/probabilitats.dat")
+		userDictionary := e.UserDictionary
+		if userDictionary == "" {
+			userDictionary = path + "data/" + lang + "/userdicc.src"
+		}
+		macoOptions.SetUserDictionary(userDictionary)
+
+		nlpOptions.MorfoOptions = macoOptions
+
+		// a sentiment lexicon scores sentences also without the disambiguator
+		if _, err := os.Stat(path + "data/" + lang + "/sentiment.dat"); err == nil {
+			nlpOptions.SentimentFile = "sentiment.dat"
+		}
+	}
+	return nlpOptions
+}
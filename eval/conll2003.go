@@ -0,0 +1,207 @@
+package eval
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/advancedlogic/go-freeling/models"
+)
+
+const DOCSTART = "-DOCSTART-"
+
+// CoNLL-2003 types of the MITIE entity types.
+var entityTypes = map[string]string{
+	"PERSON":       "PER",
+	"LOCATION":     "LOC",
+	"ORGANIZATION": "ORG",
+	"MISC":         "MISC",
+}
+
+// CoNLL chunk types of the chunker labels.
+var chunkTypes = map[string]string{
+	"sn":        "NP",
+	"np":        "NP",
+	"grup-nom":  "NP",
+	"grup-verb": "VP",
+	"vp":        "VP",
+	"grup-sp":   "PP",
+	"pp":        "PP",
+	"sa":        "ADJP",
+	"adjp":      "ADJP",
+	"sadv":      "ADVP",
+	"advp":      "ADVP",
+}
+
+// readCoNLL2003 reads the gold words of a CoNLL-2003 corpus (form, POS,
+// chunk and entity columns separated by spaces), grouped in documents by the
+// -DOCSTART- lines.
+func readCoNLL2003(r io.Reader, batch int) ([][]*goldSentence, error) {
+	documents := make([][]*goldSentence, 0)
+	current := make([]*goldSentence, 0)
+	sentence := new(goldSentence)
+
+	flushSentence := func() {
+		if len(sentence.pieces) > 0 {
+			current = append(current, sentence)
+		}
+		sentence = new(goldSentence)
+		if batch > 0 && len(current) >= batch {
+			documents = append(documents, current)
+			current = make([]*goldSentence, 0)
+		}
+	}
+	flushDocument := func() {
+		flushSentence()
+		if len(current) > 0 {
+			documents = append(documents, current)
+			current = make([]*goldSentence, 0)
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		items := strings.Fields(scanner.Text())
+		if len(items) == 0 {
+			flushSentence()
+			continue
+		}
+		if items[0] == DOCSTART {
+			flushDocument()
+			continue
+		}
+		if len(items) < 4 {
+			return nil, fmt.Errorf("line %d: expected form, POS, chunk and entity columns", n)
+		}
+		token := &goldToken{
+			form:   items[0],
+			xpos:   items[1],
+			chunk:  items[2],
+			entity: items[len(items)-1],
+		}
+		sentence.pieces = append(sentence.pieces, &goldPiece{
+			form:       items[0],
+			spaceAfter: true,
+			tokens:     []*goldToken{token},
+		})
+	}
+	flushDocument()
+	return documents, scanner.Err()
+}
+
+// EvaluateEntities analyzes the text of a CoNLL-2003 corpus and compares its
+// chunks and named entities with the gold ones. Chunks are the constituents
+// right below the root of the shallow parse trees, matched by span and type.
+// As the recognizer reports every entity once per document, entities are
+// matched by type and text within each document.
+func (this *Evaluator) EvaluateEntities(r io.Reader) (*EntityReport, error) {
+	documents, err := readCoNLL2003(r, this.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &EntityReport{
+		Chunks:   NewSpanScore(),
+		Entities: NewSpanScore(),
+	}
+	for _, sentences := range documents {
+		document, err := this.analyze(sentences)
+		if err != nil {
+			return nil, err
+		}
+
+		goldChunks := make(map[string]string)
+		goldEntities := make(map[string]string)
+		body := document.GetBody()
+		for _, sentence := range sentences {
+			report.Sentences++
+			tokens := sentence.tokens()
+			report.Tokens += len(tokens)
+			for _, span := range iobSpans(tokens, func(t *goldToken) string { return t.chunk }) {
+				goldChunks[spanString(span.start, span.finish)] = span.label
+			}
+			for _, span := range iobSpans(tokens, func(t *goldToken) string { return t.entity }) {
+				if span.finish <= len(body) {
+					goldEntities[span.label+"\t"+compact(body[span.start:span.finish])] = span.label
+				}
+			}
+		}
+		report.Chunks.compare(goldChunks, predictedChunks(document))
+
+		entities := make(map[string]string)
+		if document.Entities != nil {
+			for e := document.Entities.Front(); e != nil; e = e.Next() {
+				entity := e.Value.(*models.Entity)
+				label, ok := entityTypes[entity.GetModel()]
+				if !ok {
+					label = entity.GetModel()
+				}
+				entities[label+"\t"+compact(entity.GetValue())] = label
+			}
+		}
+		report.Entities.compare(goldEntities, entities)
+	}
+
+	report.Chunks.compute()
+	report.Entities.compute()
+	return report, nil
+}
+
+// predictedChunks returns the labels of the chunks of every sentence,
+// indexed by span.
+func predictedChunks(document *models.DocumentEntity) map[string]string {
+	chunks := make(map[string]string)
+	for s := document.Sentences().Front(); s != nil; s = s.Next() {
+		se := s.Value.(*models.SentenceEntity)
+		tree := se.GetTree()
+		if tree == nil {
+			continue
+		}
+		tokens := make([]*models.TokenEntity, 0, se.Tokens().Len())
+		for t := se.Tokens().Front(); t != nil; t = t.Next() {
+			tokens = append(tokens, t.Value.(*models.TokenEntity))
+		}
+
+		for _, chunk := range tree.Children {
+			if len(chunk.Children) == 0 {
+				continue
+			}
+			first, last := leaves(chunk, len(tokens), -1, -1)
+			if first < 0 {
+				continue
+			}
+			label, ok := chunkTypes[chunk.Label]
+			if !ok {
+				label = strings.ToUpper(chunk.Label)
+			}
+			chunks[spanString(tokens[first].GetSpanStart(), tokens[last].GetSpanFinish())] = label
+		}
+	}
+	return chunks
+}
+
+// leaves returns the first and last token indexes under a tree node.
+func leaves(node *models.ParseTreeEntity, ntokens int, first int, last int) (int, int) {
+	if node.Word >= 0 && node.Word < ntokens {
+		if first < 0 || node.Word < first {
+			first = node.Word
+		}
+		if node.Word > last {
+			last = node.Word
+		}
+	}
+	for _, child := range node.Children {
+		first, last = leaves(child, ntokens, first, last)
+	}
+	return first, last
+}
+
+// compact removes the spaces of an entity, which the recognizer tokenizes on
+// its own.
+func compact(value string) string {
+	return strings.Join(strings.Fields(value), "")
+}
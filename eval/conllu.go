@@ -0,0 +1,144 @@
+package eval
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// readCoNLLU reads the gold words of a CoNLL-U corpus, grouped in documents
+// by the newdoc comments. Multiword token ranges become pieces holding the
+// words they split into.
+func readCoNLLU(r io.Reader, batch int) ([][]*goldSentence, error) {
+	documents := make([][]*goldSentence, 0)
+	current := make([]*goldSentence, 0)
+	sentence := new(goldSentence)
+	rangeEnd := 0
+	var rangePiece *goldPiece
+
+	flushSentence := func() {
+		if len(sentence.pieces) > 0 {
+			current = append(current, sentence)
+		}
+		sentence = new(goldSentence)
+		rangeEnd = 0
+		rangePiece = nil
+		if batch > 0 && len(current) >= batch {
+			documents = append(documents, current)
+			current = make([]*goldSentence, 0)
+		}
+	}
+	flushDocument := func() {
+		flushSentence()
+		if len(current) > 0 {
+			documents = append(documents, current)
+			current = make([]*goldSentence, 0)
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			flushSentence()
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			comment := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			if strings.HasPrefix(comment, "newdoc") {
+				flushDocument()
+			} else if strings.HasPrefix(comment, "text =") {
+				sentence.text = strings.TrimSpace(strings.TrimPrefix(comment, "text ="))
+			}
+			continue
+		}
+
+		items := strings.Split(line, "\t")
+		if len(items) < 10 {
+			return nil, fmt.Errorf("line %d: expected 10 tab separated columns", n)
+		}
+		spaceAfter := !strings.Contains(items[9], "SpaceAfter=No")
+
+		// empty nodes have no surface
+		if strings.Contains(items[0], ".") {
+			continue
+		}
+		if i := strings.Index(items[0], "-"); i >= 0 {
+			rangeEnd, _ = strconv.Atoi(items[0][i+1:])
+			rangePiece = &goldPiece{form: items[1], spaceAfter: spaceAfter}
+			sentence.pieces = append(sentence.pieces, rangePiece)
+			continue
+		}
+
+		token := &goldToken{
+			form:  items[1],
+			lemma: items[2],
+			upos:  items[3],
+			xpos:  items[4],
+		}
+		id, _ := strconv.Atoi(items[0])
+		if rangePiece != nil && id <= rangeEnd {
+			rangePiece.tokens = append(rangePiece.tokens, token)
+			continue
+		}
+		rangePiece = nil
+		sentence.pieces = append(sentence.pieces, &goldPiece{
+			form:       items[1],
+			spaceAfter: spaceAfter,
+			tokens:     []*goldToken{token},
+		})
+	}
+	flushDocument()
+	return documents, scanner.Err()
+}
+
+// EvaluateTagging analyzes the text of a CoNLL-U corpus and compares the
+// XPOS and UPOS tags and the lemmas of every gold word with the predicted
+// ones. Lemmas are compared regardless of case, as the dictionary lemmas are
+// lowercase.
+func (this *Evaluator) EvaluateTagging(r io.Reader) (*TaggingReport, error) {
+	documents, err := readCoNLLU(r, this.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TaggingReport{
+		XPOS:  NewTagScore(),
+		UPOS:  NewTagScore(),
+		Lemma: new(Score),
+	}
+	for _, sentences := range documents {
+		document, err := this.analyze(sentences)
+		if err != nil {
+			return nil, err
+		}
+		aligned := align(sentences, predictions(document))
+
+		for _, sentence := range sentences {
+			report.Sentences++
+			for _, token := range sentence.tokens() {
+				report.Tokens++
+				p, ok := aligned[token]
+				if !ok {
+					report.Unaligned++
+					p = &predicted{lemma: UNALIGNED, pos: UNALIGNED, upos: UNALIGNED}
+				}
+				if token.xpos != "_" {
+					report.XPOS.add(token.xpos, p.pos, p.known, ok)
+				}
+				if token.upos != "_" {
+					report.UPOS.add(token.upos, p.upos, p.known, ok)
+				}
+				if token.lemma != "_" {
+					report.Lemma.add(ok && strings.ToLower(token.lemma) == strings.ToLower(p.lemma))
+				}
+			}
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,349 @@
+package eval
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	. "github.com/advancedlogic/go-freeling/lib"
+	"github.com/advancedlogic/go-freeling/models"
+)
+
+const (
+	FORMAT_CONLLU    = "conllu"
+	FORMAT_CONLL2003 = "conll2003"
+)
+
+// predicted tag of gold tokens the analyzer did not produce
+const UNALIGNED = "_"
+
+var ErrAnalysis = errors.New("eval: analysis failed")
+
+// Report gathers the results of an evaluation, to be serialized as JSON.
+type Report struct {
+	Tagging  *TaggingReport `json:"tagging,omitempty"`
+	Entities *EntityReport  `json:"entities,omitempty"`
+}
+
+// TaggingReport compares tags and lemmas against a CoNLL-U corpus.
+type TaggingReport struct {
+	Sentences int       `json:"sentences"`
+	Tokens    int       `json:"tokens"`
+	Unaligned int       `json:"unaligned"`
+	XPOS      *TagScore `json:"xpos"`
+	UPOS      *TagScore `json:"upos"`
+	Lemma     *Score    `json:"lemma"`
+}
+
+// EntityReport compares chunks and named entities against a CoNLL-2003
+// corpus.
+type EntityReport struct {
+	Sentences int        `json:"sentences"`
+	Tokens    int        `json:"tokens"`
+	Chunks    *SpanScore `json:"chunks"`
+	Entities  *SpanScore `json:"entities"`
+}
+
+// Score counts correct decisions out of a total.
+type Score struct {
+	Correct  int     `json:"correct"`
+	Total    int     `json:"total"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+func (this *Score) add(correct bool) {
+	this.Total++
+	if correct {
+		this.Correct++
+	}
+	this.Accuracy = float64(this.Correct) / float64(this.Total)
+}
+
+// TagScore is the accuracy of a tag, split between words found in the
+// dictionary and guessed ones, with its confusion matrix indexed by gold
+// and predicted tag.
+type TagScore struct {
+	Score
+	Known     Score                     `json:"known"`
+	Unknown   Score                     `json:"unknown"`
+	Confusion map[string]map[string]int `json:"confusion"`
+}
+
+func NewTagScore() *TagScore {
+	return &TagScore{Confusion: make(map[string]map[string]int)}
+}
+
+func (this *TagScore) add(gold string, predicted string, known bool, aligned bool) {
+	correct := gold == predicted
+	this.Score.add(correct)
+	if aligned {
+		if known {
+			this.Known.add(correct)
+		} else {
+			this.Unknown.add(correct)
+		}
+	}
+	row := this.Confusion[gold]
+	if row == nil {
+		row = make(map[string]int)
+		this.Confusion[gold] = row
+	}
+	row[predicted]++
+}
+
+// PRF holds precision, recall and F1 out of true positive, false positive
+// and false negative counts.
+type PRF struct {
+	TruePositives  int     `json:"tp"`
+	FalsePositives int     `json:"fp"`
+	FalseNegatives int     `json:"fn"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+	F1             float64 `json:"f1"`
+}
+
+func (this *PRF) compute() {
+	if n := this.TruePositives + this.FalsePositives; n > 0 {
+		this.Precision = float64(this.TruePositives) / float64(n)
+	}
+	if n := this.TruePositives + this.FalseNegatives; n > 0 {
+		this.Recall = float64(this.TruePositives) / float64(n)
+	}
+	if this.Precision+this.Recall > 0 {
+		this.F1 = 2 * this.Precision * this.Recall / (this.Precision + this.Recall)
+	}
+}
+
+// SpanScore is the overall PRF of labeled spans and the PRF of each label.
+type SpanScore struct {
+	PRF
+	Types map[string]*PRF `json:"types"`
+}
+
+func NewSpanScore() *SpanScore {
+	return &SpanScore{Types: make(map[string]*PRF)}
+}
+
+func (this *SpanScore) get(label string) *PRF {
+	prf := this.Types[label]
+	if prf == nil {
+		prf = new(PRF)
+		this.Types[label] = prf
+	}
+	return prf
+}
+
+// compare scores predicted against gold labels, both indexed by span.
+func (this *SpanScore) compare(gold map[string]string, predicted map[string]string) {
+	for key, label := range predicted {
+		if gold[key] == label {
+			this.TruePositives++
+			this.get(label).TruePositives++
+		} else {
+			this.FalsePositives++
+			this.get(label).FalsePositives++
+		}
+	}
+	for key, label := range gold {
+		if predicted[key] != label {
+			this.FalseNegatives++
+			this.get(label).FalseNegatives++
+		}
+	}
+}
+
+func (this *SpanScore) compute() {
+	this.PRF.compute()
+	for _, prf := range this.Types {
+		prf.compute()
+	}
+}
+
+// Evaluator runs the analyzer on the text of gold annotated corpora and
+// aligns its output to the gold tokens by their offsets in the text.
+type Evaluator struct {
+	analyzer *Analyzer
+	// sentences analyzed at once when the corpus has no document marks
+	BatchSize int
+}
+
+func NewEvaluator(analyzer *Analyzer) *Evaluator {
+	return &Evaluator{
+		analyzer:  analyzer,
+		BatchSize: 50,
+	}
+}
+
+type goldToken struct {
+	form   string
+	lemma  string
+	upos   string
+	xpos   string
+	chunk  string
+	entity string
+	start  int
+	finish int
+}
+
+// goldPiece is a surface word of the text, which a contraction splits in
+// several gold tokens.
+type goldPiece struct {
+	form       string
+	spaceAfter bool
+	tokens     []*goldToken
+}
+
+type goldSentence struct {
+	text   string
+	pieces []*goldPiece
+}
+
+func (this *goldSentence) tokens() []*goldToken {
+	tokens := make([]*goldToken, 0, len(this.pieces))
+	for _, piece := range this.pieces {
+		tokens = append(tokens, piece.tokens...)
+	}
+	return tokens
+}
+
+// analyze builds the text of the sentences, one per line, locates their
+// gold tokens in it and analyzes it.
+func (this *Evaluator) analyze(sentences []*goldSentence) (*models.DocumentEntity, error) {
+	text := ""
+	for _, sentence := range sentences {
+		line := sentence.text
+		if line == "" {
+			for _, piece := range sentence.pieces {
+				line += piece.form
+				if piece.spaceAfter {
+					line += " "
+				}
+			}
+			line = strings.TrimRight(line, " ")
+		}
+
+		cursor := 0
+		for _, piece := range sentence.pieces {
+			start, finish := -1, -1
+			if i := strings.Index(line[cursor:], piece.form); i >= 0 && piece.form != "" {
+				start = len(text) + cursor + i
+				finish = start + len(piece.form)
+				cursor += i + len(piece.form)
+			}
+			for _, token := range piece.tokens {
+				token.start, token.finish = start, finish
+			}
+		}
+		text += line + "\n"
+	}
+
+	document := models.NewDocumentEntity()
+	document.Content = text
+	output := this.analyzer.AnalyzeText(document)
+	if output == nil {
+		return nil, ErrAnalysis
+	}
+	return output, nil
+}
+
+type predicted struct {
+	lemma string
+	pos   string
+	upos  string
+	known bool
+}
+
+func spanKey(start int, finish int) [2]int {
+	return [2]int{start, finish}
+}
+
+// predictions indexes the analyzed words by span. Words of a contraction
+// share the span of the contracted form, and the components of a multiword
+// get the tags of the multiword.
+func predictions(document *models.DocumentEntity) map[[2]int][]*predicted {
+	index := make(map[[2]int][]*predicted)
+	for s := document.Sentences().Front(); s != nil; s = s.Next() {
+		for t := s.Value.(*models.SentenceEntity).Tokens().Front(); t != nil; t = t.Next() {
+			te := t.Value.(*models.TokenEntity)
+			key := spanKey(te.GetSpanStart(), te.GetSpanFinish())
+			index[key] = append(index[key], &predicted{
+				lemma: te.GetLemma(),
+				pos:   te.GetPos(),
+				upos:  te.GetUPOS(),
+				known: te.IsInDict(),
+			})
+
+			components := te.GetComponents()
+			lemmas := strings.Split(te.GetLemma(), "_")
+			for i, ce := range components {
+				lemma := te.GetLemma()
+				if len(lemmas) == len(components) {
+					lemma = lemmas[i]
+				}
+				key := spanKey(ce.GetSpanStart(), ce.GetSpanFinish())
+				index[key] = append(index[key], &predicted{
+					lemma: lemma,
+					pos:   te.GetPos(),
+					upos:  te.GetUPOS(),
+					known: te.IsInDict(),
+				})
+			}
+		}
+	}
+	return index
+}
+
+// align returns the prediction for every gold token, nil if there is none.
+func align(sentences []*goldSentence, index map[[2]int][]*predicted) map[*goldToken]*predicted {
+	aligned := make(map[*goldToken]*predicted)
+	for _, sentence := range sentences {
+		for _, piece := range sentence.pieces {
+			if len(piece.tokens) == 0 || piece.tokens[0].start < 0 {
+				continue
+			}
+			candidates := index[spanKey(piece.tokens[0].start, piece.tokens[0].finish)]
+			for i, token := range piece.tokens {
+				if i < len(candidates) {
+					aligned[token] = candidates[i]
+				}
+			}
+		}
+	}
+	return aligned
+}
+
+type goldSpan struct {
+	label  string
+	start  int
+	finish int
+}
+
+// iobSpans decodes IOB1 or IOB2 tags into labeled spans of the text.
+func iobSpans(tokens []*goldToken, tag func(*goldToken) string) []goldSpan {
+	spans := make([]goldSpan, 0)
+	current := goldSpan{start: -1, finish: -1}
+	flush := func() {
+		if current.label != "" && current.start >= 0 && current.finish >= 0 {
+			spans = append(spans, current)
+		}
+		current = goldSpan{start: -1, finish: -1}
+	}
+	for _, token := range tokens {
+		t := tag(token)
+		if len(t) < 3 || t[1] != '-' {
+			flush()
+			continue
+		}
+		if t[0] == 'B' || t[2:] != current.label {
+			flush()
+			current.label, current.start = t[2:], token.start
+		}
+		current.finish = token.finish
+	}
+	flush()
+	return spans
+}
+
+func spanString(start int, finish int) string {
+	return strconv.Itoa(start) + ":" + strconv.Itoa(finish)
+}
@@ -1,9 +1,27 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/advancedlogic/go-freeling/eval"
 	. "github.com/advancedlogic/go-freeling/lib"
+	"github.com/advancedlogic/go-freeling/models"
 	. "github.com/advancedlogic/go-freeling/net"
+	"github.com/advancedlogic/go-freeling/nlp"
 	. "github.com/advancedlogic/go-freeling/terminal"
+	"github.com/advancedlogic/go-freeling/writer"
 )
 
 var logo = `
@@ -16,16 +34,592 @@ var logo = `
 			AdvancedLogic 2015 - v.0.1
 `
 
-func init() {
+const DEFAULT_CONFIG = "conf/gofreeling.toml"
+
+var usage = `Usage: gofreeling [command] [flags] [files]
+
+Commands:
+  serve     start the HTTP server (default)
+  analyze   analyze files or the standard input and write the results to the standard output
+  eval      evaluate the pipeline on a gold corpus (CoNLL-U or CoNLL-2003) and
+            write the scores as JSON
+  stress    analyze files concurrently and check the results match a sequential
+            run (build with -race to also detect data races)
+  crawl     analyze the pages of the sites of the given URLs, following their
+            links, and write the entities and unknown words of each site
+  compile-data
+            compile the dictionary, multiwords, probabilities and tagger
+            model of a language to the binary format loaded at startup
+  train-tagger
+            train an HMM tagger model (tagger.dat) from a tagged corpus
+  train-probabilities
+            train the lexical probabilities and unknown word guesser
+            (probabilitats.dat) from a tagged corpus and the dictionary
+
+Run gofreeling <command> -h for the flags of each command.
+`
+
+func main() {
+	command := "serve"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+
+	switch command {
+	case "serve":
+		serve(args)
+	case "analyze":
+		os.Exit(analyze(args))
+	case "eval":
+		os.Exit(evaluate(args))
+	case "stress":
+		os.Exit(stress(args))
+	case "crawl":
+		os.Exit(crawl(args))
+	case "compile-data":
+		os.Exit(compileData(args))
+	case "train-tagger":
+		os.Exit(trainTagger(args))
+	case "train-probabilities":
+		os.Exit(trainProbabilities(args))
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func banner() {
 	Infof("Go - Freeling - Natural Language Processing for Golang\n")
 	Infof("This is a partial port of Freeling 3.1\n")
 }
 
-func main() {
-	analyzer := NewAnalyzer()
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	config := flags.String("config", DEFAULT_CONFIG, "configuration file")
+	lang := flags.String("lang", "", "language (overrides the configuration)")
+	socket := flags.Int64("socket", 0, "also serve the FreeLing socket protocol on this port (overrides the configuration)")
+	flags.Parse(args)
+
+	banner()
+	analyzer := NewCustomAnalyzer(*config, *lang, "")
 
 	println(logo)
 
+	// kill -HUP reloads the linguistic data
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			if err := analyzer.Reload(); err != nil {
+				Errorln("SIGHUP reload failed: " + err.Error())
+			}
+		}
+	}()
+
+	port := *socket
+	if port == 0 && analyzer.Bool("socket.enabled", false) {
+		port = analyzer.Int64("socket.port", 50005)
+	}
+	if port > 0 {
+		socketServer := NewSocketServer(analyzer)
+		go func() {
+			if err := socketServer.Listen(port); err != nil {
+				Errorln(err.Error())
+			}
+		}()
+	}
+
 	httpServer := NewHttpServer(analyzer)
 	httpServer.Listen()
 }
+
+func analyze(args []string) int {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	config := flags.String("config", DEFAULT_CONFIG, "configuration file")
+	lang := flags.String("lang", "", "language (overrides the configuration)")
+	level := flags.String("level", "", "pipeline stop level: token, splitted, morfo, tagged, shallow or sense (default: whole pipeline)")
+	input := flags.String("input", "text", "input format: text, html, or url for one URL per line")
+	output := flags.String("output", "json", "output format: json, conllu or text")
+	stream := flags.Bool("stream", false, "write every sentence as soon as it is analyzed, reading large text inputs in chunks (json output is one sentence per line)")
+	flags.Parse(args)
+
+	if *level != "" && !writer.IsLevel(*level) {
+		fmt.Fprintf(os.Stderr, "unknown level %s\n", *level)
+		return 2
+	}
+	if *input != "text" && *input != "html" && *input != "url" {
+		fmt.Fprintf(os.Stderr, "unknown input format %s\n", *input)
+		return 2
+	}
+	if *output != "json" && *output != "conllu" && *output != "text" {
+		fmt.Fprintf(os.Stderr, "unknown output format %s\n", *output)
+		return 2
+	}
+	if *stream && *input != "text" {
+		fmt.Fprintln(os.Stderr, "only text input can be streamed")
+		return 2
+	}
+
+	// keep the standard output for the results
+	SetOutput(os.Stderr)
+	analyzer := NewCustomAnalyzer(*config, *lang, *level)
+
+	textLevel := analyzer.Level()
+	if textLevel == "" {
+		textLevel = writer.LEVEL_TAGGED
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	status := 0
+	process := func(document *models.DocumentEntity) {
+		result := analyzer.AnalyzeText(document)
+		if result == nil {
+			fmt.Fprintf(os.Stderr, "error analyzing %s\n", document.String())
+			status = 1
+			return
+		}
+		if failure := result.GetError(); failure != nil {
+			fmt.Fprintln(os.Stderr, failure.Message)
+			status = 1
+			return
+		}
+		switch *output {
+		case "conllu":
+			out.WriteString(result.ToCoNLLU())
+		case "text":
+			writer.NewWriter(textLevel).Write(out, result)
+		default:
+			b, err := json.Marshal(result.ToJSON())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				status = 1
+				return
+			}
+			out.Write(b)
+			out.WriteString("\n")
+		}
+	}
+
+	sid := 0
+	processStream := func(r io.Reader) {
+		paragraph := 0
+		for se := range analyzer.AnalyzeStream(r, nil) {
+			sid++
+			switch *output {
+			case "conllu":
+				out.WriteString(se.ToCoNLLU(sid, se.GetParagraph() != paragraph))
+			case "text":
+				writer.NewWriter(textLevel).WriteSentence(out, se)
+			default:
+				b, err := json.Marshal(se.ToJSON())
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err.Error())
+					status = 1
+					continue
+				}
+				out.Write(b)
+				out.WriteString("\n")
+			}
+			paragraph = se.GetParagraph()
+		}
+	}
+
+	read := func(name string, r io.Reader) {
+		if *stream {
+			processStream(r)
+			return
+		}
+		if *input == "url" {
+			scanner := bufio.NewScanner(r)
+			for scanner.Scan() {
+				url := strings.TrimSpace(scanner.Text())
+				if url == "" {
+					continue
+				}
+				document := new(models.DocumentEntity)
+				document.Url = url
+				process(document)
+			}
+			return
+		}
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %s\n", name, err.Error())
+			status = 1
+			return
+		}
+		document := new(models.DocumentEntity)
+		if *input == "html" {
+			document.HTML = string(content)
+		} else {
+			document.Content = string(content)
+		}
+		process(document)
+	}
+
+	if flags.NArg() == 0 {
+		read("stdin", os.Stdin)
+		return status
+	}
+	for _, name := range flags.Args() {
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error opening %s: %s\n", name, err.Error())
+			status = 1
+			continue
+		}
+		read(name, file)
+		file.Close()
+	}
+	return status
+}
+
+func evaluate(args []string) int {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	config := flags.String("config", DEFAULT_CONFIG, "configuration file")
+	lang := flags.String("lang", "", "language (overrides the configuration)")
+	format := flags.String("format", eval.FORMAT_CONLLU, "gold corpus format: conllu (tags and lemmas) or conll2003 (chunks and entities)")
+	batch := flags.Int("batch", 50, "sentences analyzed at once between document marks")
+	flags.Parse(args)
+
+	if *format != eval.FORMAT_CONLLU && *format != eval.FORMAT_CONLL2003 {
+		fmt.Fprintf(os.Stderr, "unknown corpus format %s\n", *format)
+		return 2
+	}
+
+	var in io.Reader = os.Stdin
+	name := "stdin"
+	if flags.NArg() > 0 {
+		name = flags.Arg(0)
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error opening %s: %s\n", name, err.Error())
+			return 1
+		}
+		defer file.Close()
+		in = file
+	}
+
+	// keep the standard output for the results
+	SetOutput(os.Stderr)
+	evaluator := eval.NewEvaluator(NewCustomAnalyzer(*config, *lang, ""))
+	evaluator.BatchSize = *batch
+
+	report := new(eval.Report)
+	var err error
+	if *format == eval.FORMAT_CONLL2003 {
+		report.Entities, err = evaluator.EvaluateEntities(in)
+	} else {
+		report.Tagging, err = evaluator.EvaluateTagging(in)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error evaluating %s: %s\n", name, err.Error())
+		return 1
+	}
+
+	b, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	os.Stdout.Write(append(b, '\n'))
+	return 0
+}
+
+// stress analyzes the files once sequentially and then rounds times from
+// as many goroutines as workers, reporting the results that differ from the
+// sequential ones.
+func stress(args []string) int {
+	flags := flag.NewFlagSet("stress", flag.ExitOnError)
+	config := flags.String("config", DEFAULT_CONFIG, "configuration file")
+	lang := flags.String("lang", "", "language (overrides the configuration)")
+	workers := flags.Int("workers", 8, "concurrent analyses")
+	rounds := flags.Int("rounds", 4, "times every file is analyzed concurrently")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 || *workers < 1 || *rounds < 1 {
+		fmt.Fprintln(os.Stderr, "usage: gofreeling stress [-workers n] [-rounds n] files...")
+		return 2
+	}
+
+	contents := make([]string, 0, flags.NArg())
+	for _, name := range flags.Args() {
+		content, err := ioutil.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %s\n", name, err.Error())
+			return 1
+		}
+		contents = append(contents, string(content))
+	}
+
+	SetOutput(os.Stderr)
+	analyzer := NewCustomAnalyzer(*config, *lang, "")
+
+	// the results without the document id and timestamp
+	run := func(content string) string {
+		document := new(models.DocumentEntity)
+		document.Content = content
+		result := analyzer.AnalyzeText(document)
+		if result == nil {
+			return ""
+		}
+		js := result.ToJSON().(map[string]interface{})
+		delete(js, "id")
+		delete(js, "timestamp")
+		b, _ := json.Marshal(js)
+		return string(b)
+	}
+
+	expected := make([]string, len(contents))
+	for i, content := range contents {
+		expected[i] = run(content)
+		if expected[i] == "" {
+			fmt.Fprintf(os.Stderr, "error analyzing %s\n", flags.Arg(i))
+			return 1
+		}
+	}
+
+	jobs := make(chan int)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	failures := 0
+	for w := 0; w < *workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if run(contents[i]) != expected[i] {
+					lock.Lock()
+					failures++
+					fmt.Fprintf(os.Stderr, "concurrent result of %s differs from the sequential one\n", flags.Arg(i))
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	for r := 0; r < *rounds; r++ {
+		for i := range contents {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	total := *rounds * len(contents)
+	fmt.Printf("%d concurrent analyses with %d workers, %d differ\n", total, *workers, failures)
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
+
+// crawl analyzes the sites of the seed URLs given as arguments and writes a
+// json line per site, with its pages and the entities and unknown words of
+// all of them.
+func crawl(args []string) int {
+	flags := flag.NewFlagSet("crawl", flag.ExitOnError)
+	config := flags.String("config", DEFAULT_CONFIG, "configuration file")
+	lang := flags.String("lang", "", "language (overrides the configuration)")
+	pages := flags.Int("pages", 0, "pages analyzed per site, at most the crawler.pages of the configuration (default: crawler.pages)")
+	levels := flags.Int("levels", 0, "links followed from the seed page, at most the crawler.levels of the configuration (default: crawler.levels)")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: gofreeling crawl [-pages n] [-levels n] urls...")
+		return 2
+	}
+
+	SetOutput(os.Stderr)
+	analyzer := NewCustomAnalyzer(*config, *lang, "")
+
+	status := 0
+	for _, url := range flags.Args() {
+		site := models.NewSiteEntity(url)
+		site.MaxPages = *pages
+		site.MaxLevels = *levels
+		result := analyzer.AnalyzeSite(context.Background(), site)
+		if failure := result.GetError(); failure != nil {
+			fmt.Fprintln(os.Stderr, failure.Message)
+			status = 1
+			continue
+		}
+		b, err := json.Marshal(result.ToJSON())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			status = 1
+			continue
+		}
+		fmt.Println(string(b))
+	}
+	return status
+}
+
+func compileData(args []string) int {
+	flags := flag.NewFlagSet("compile-data", flag.ExitOnError)
+	lang := flags.String("lang", "en", "language")
+	path := flags.String("path", "./", "data path")
+	flags.Parse(args)
+
+	dir := filepath.Join(*path, "data", *lang) + "/"
+	resources := []struct {
+		file    string
+		compile func(string) error
+	}{
+		{dir + "dicc.src", func(file string) error {
+			return nlp.NewDictionary(*lang, file, "", "", false, true).Compile(file)
+		}},
+		{dir + "locucions-extended.dat", func(file string) error {
+			return nlp.NewLocutions(file).Compile(file)
+		}},
+		{dir + "probabilitats.dat", func(file string) error {
+			return nlp.NewProbability(file, 0.001).Compile(file)
+		}},
+		{dir + "tagger.dat", func(file string) error {
+			return nlp.NewHMMTagger(file, true, nlp.FORCE_TAGGER, 1).Compile(file)
+		}},
+	}
+
+	status := 0
+	for _, resource := range resources {
+		if _, err := os.Stat(resource.file); err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %s: %s\n", resource.file, err.Error())
+			continue
+		}
+		if err := resource.compile(resource.file); err != nil {
+			fmt.Fprintf(os.Stderr, "error compiling %s: %s\n", resource.file, err.Error())
+			status = 1
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s -> %s\n", resource.file, nlp.BinaryFile(resource.file))
+	}
+	return status
+}
+
+func trainTagger(args []string) int {
+	flags := flag.NewFlagSet("train-tagger", flag.ExitOnError)
+	lang := flags.String("lang", "en", "language, to locate the tagset")
+	path := flags.String("path", "./", "data path")
+	tagset := flags.String("tagset", "", "tagset file (default: <path>/data/<lang>/tagset.dat)")
+	format := flags.String("format", nlp.CORPUS_FREELING, "corpus format: freeling (form lemma tag) or conll")
+	output := flags.String("output", "tagger.dat", "model file to write")
+	flags.Parse(args)
+
+	if *format != nlp.CORPUS_FREELING && *format != nlp.CORPUS_CONLL {
+		fmt.Fprintf(os.Stderr, "unknown corpus format %s\n", *format)
+		return 2
+	}
+	if *tagset == "" {
+		*tagset = filepath.Join(*path, "data", *lang, "tagset.dat")
+	}
+
+	trainer := nlp.NewHMMTrainer(nlp.NewTagset(*tagset))
+	if flags.NArg() == 0 {
+		if err := trainer.ReadCorpus(os.Stdin, *format); err != nil {
+			fmt.Fprintf(os.Stderr, "error reading stdin: %s\n", err.Error())
+			return 1
+		}
+	}
+	for _, name := range flags.Args() {
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error opening %s: %s\n", name, err.Error())
+			return 1
+		}
+		err = trainer.ReadCorpus(file, *format)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %s\n", name, err.Error())
+			return 1
+		}
+	}
+
+	// the tagger loads the tagset relative to its own directory
+	ftags, err := filepath.Rel(filepath.Dir(*output), *tagset)
+	if err != nil {
+		ftags, _ = filepath.Abs(*tagset)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error creating %s: %s\n", *output, err.Error())
+		return 1
+	}
+	defer file.Close()
+	if err := trainer.Write(file, ftags); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing %s: %s\n", *output, err.Error())
+		return 1
+	}
+	return 0
+}
+
+func trainProbabilities(args []string) int {
+	flags := flag.NewFlagSet("train-probabilities", flag.ExitOnError)
+	lang := flags.String("lang", "en", "language, to locate the tagset and the dictionary")
+	path := flags.String("path", "./", "data path")
+	tagset := flags.String("tagset", "", "tagset file (default: <path>/data/<lang>/tagset.dat)")
+	dictionary := flags.String("dictionary", "", "dictionary source file (default: <path>/data/<lang>/dicc.src)")
+	format := flags.String("format", nlp.CORPUS_FREELING, "corpus format: freeling (form lemma tag) or conll")
+	suffix := flags.Int("suffix", 10, "maximum length of the suffixes used to guess unknown words")
+	rare := flags.Int("rare", 10, "maximum frequency of the words whose suffixes are collected")
+	output := flags.String("output", "probabilitats.dat", "model file to write")
+	flags.Parse(args)
+
+	if *format != nlp.CORPUS_FREELING && *format != nlp.CORPUS_CONLL {
+		fmt.Fprintf(os.Stderr, "unknown corpus format %s\n", *format)
+		return 2
+	}
+	if *tagset == "" {
+		*tagset = filepath.Join(*path, "data", *lang, "tagset.dat")
+	}
+	if *dictionary == "" {
+		*dictionary = filepath.Join(*path, "data", *lang, "dicc.src")
+	}
+
+	trainer := nlp.NewProbabilityTrainer(nlp.NewTagset(*tagset))
+	trainer.MaxSuffix = *suffix
+	trainer.RareWords = *rare
+	if !trainer.LoadDictionary(*dictionary) {
+		fmt.Fprintf(os.Stderr, "error opening %s\n", *dictionary)
+		return 1
+	}
+	if flags.NArg() == 0 {
+		if err := trainer.ReadCorpus(os.Stdin, *format); err != nil {
+			fmt.Fprintf(os.Stderr, "error reading stdin: %s\n", err.Error())
+			return 1
+		}
+	}
+	for _, name := range flags.Args() {
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error opening %s: %s\n", name, err.Error())
+			return 1
+		}
+		err = trainer.ReadCorpus(file, *format)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %s\n", name, err.Error())
+			return 1
+		}
+	}
+
+	// the model loads the tagset relative to its own directory
+	ftags, err := filepath.Rel(filepath.Dir(*output), *tagset)
+	if err != nil {
+		ftags, _ = filepath.Abs(*tagset)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error creating %s: %s\n", *output, err.Error())
+		return 1
+	}
+	defer file.Close()
+	if err := trainer.Write(file, ftags); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing %s: %s\n", *output, err.Error())
+		return 1
+	}
+	return 0
+}
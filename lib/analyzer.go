@@ -1,8 +1,14 @@
 package lib
 
 import (
+	"context"
+	"io"
+	"strings"
+
 	. "github.com/advancedlogic/go-freeling/engine"
 	"github.com/advancedlogic/go-freeling/models"
+	"github.com/advancedlogic/go-freeling/nlp"
+	"github.com/advancedlogic/go-freeling/wordnet"
 )
 
 type Analyzer struct {
@@ -10,7 +16,19 @@ type Analyzer struct {
 }
 
 func NewAnalyzer() *Analyzer {
-	context := NewContext("conf/gofreeling.toml")
+	return NewCustomAnalyzer("conf/gofreeling.toml", "", "")
+}
+
+// NewCustomAnalyzer creates an analyzer from the given configuration file.
+// Non empty lang and level override the ones in the configuration.
+func NewCustomAnalyzer(configFile string, lang string, level string) *Analyzer {
+	context := NewContext(configFile)
+	if lang != "" {
+		context.Engine.Lang = lang
+	}
+	if level != "" {
+		context.Engine.Level = level
+	}
 	context.InitNLP()
 	instance := new(Analyzer)
 	instance.context = context
@@ -18,16 +36,177 @@ func NewAnalyzer() *Analyzer {
 	return instance
 }
 
+func (this *Analyzer) Level() string {
+	return this.context.Engine.Level
+}
+
 func (this *Analyzer) Int64(key string, def int64) int64 {
 	return this.context.Int64(key, def)
 }
 
+func (this *Analyzer) Bool(key string, def bool) bool {
+	return this.context.Bool(key, def)
+}
+
+func (this *Analyzer) String(key string, def string) string {
+	return this.context.Configuration.String(key, def)
+}
+
 func (this *Analyzer) AnalyzeText(document *models.DocumentEntity) *models.DocumentEntity {
 	ch := make(chan *models.DocumentEntity)
 	defer close(ch)
 
-	go this.context.Engine.NLP.Workflow(document, ch)
+	nlpEngine, done := this.context.Engine.Acquire()
+	defer done()
+	go nlpEngine.Workflow(document, ch)
 	output := <-ch
 
 	return output
 }
+
+// AnalyzeStream analyzes a text read from r, sending its sentences as soon
+// as they are complete. The channel is closed at the end of the text, or
+// once done is closed by a consumer that stops before the end (nil if it
+// always reads to the end).
+func (this *Analyzer) AnalyzeStream(r io.Reader, done <-chan struct{}) <-chan *models.SentenceEntity {
+	nlpEngine, release := this.context.Engine.Acquire()
+	input := nlpEngine.AnalyzeStream(r, done)
+	output := make(chan *models.SentenceEntity)
+	go func() {
+		defer release()
+		defer close(output)
+		// the engine is released once its stream is over, also when the
+		// consumer stops early
+		for se := range input {
+			select {
+			case output <- se:
+			case <-done:
+			}
+		}
+	}()
+	return output
+}
+
+// Stream is a stream analysis session for a text given in pieces, such as the
+// messages of a socket connection. It keeps the engine it was opened on
+// until it is closed, also across reloads.
+type Stream struct {
+	stream  *nlp.TextStream
+	release func()
+}
+
+// NewStream opens a stream analysis session on the current engine.
+func (this *Analyzer) NewStream() (*Stream, error) {
+	nlpEngine, release := this.context.Engine.Acquire()
+	stream, err := nlpEngine.NewTextStream()
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &Stream{stream: stream, release: release}, nil
+}
+
+// Write adds a piece of text and returns the sentences it completes.
+func (this *Stream) Write(text string) []*models.SentenceEntity {
+	sentences := make([]*models.SentenceEntity, 0)
+	this.stream.Write(text, func(se *models.SentenceEntity) bool {
+		sentences = append(sentences, se)
+		return true
+	})
+	return sentences
+}
+
+// Flush returns the sentences left, ending the last one even without a
+// sentence marker.
+func (this *Stream) Flush() []*models.SentenceEntity {
+	sentences := make([]*models.SentenceEntity, 0)
+	this.stream.Flush(func(se *models.SentenceEntity) bool {
+		sentences = append(sentences, se)
+		return true
+	})
+	return sentences
+}
+
+// Close ends the session and releases its engine.
+func (this *Stream) Close() {
+	this.stream.Close()
+	this.release()
+}
+
+// AnalyzeSite crawls the site of site.Url, following its links to the same
+// host, and analyzes every page. The entities and unknown words of the pages
+// are added up in the site. The crawl stops once ctx is done, such as when
+// the client of a request goes away.
+func (this *Analyzer) AnalyzeSite(ctx context.Context, site *models.SiteEntity) *models.SiteEntity {
+	site.Init()
+	job := this.context.Engine.Crawler.NewCrawlJob(site.Url)
+	job.SetPages(site.MaxPages)
+	job.SetLevels(site.MaxLevels)
+
+	err := job.Run(ctx, func(page *nlp.CrawledPage) bool {
+		if page.Err != nil {
+			site.AddFailure(page.URL, page.Level, nlp.CrawlErrorEntity(page.URL, page.Err))
+			return true
+		}
+
+		document := new(models.DocumentEntity)
+		document.Url = page.URL
+		document.Title = page.Article.Title
+		document.Description = page.Article.MetaDescription
+		document.Keywords = page.Article.MetaKeywords
+		document.TopImage = page.Article.TopImage
+		document.Content = page.Article.CleanedText
+		document.Language = site.Language
+		document.Flags = site.Flags
+		document.Domains = site.Domains
+		if strings.TrimSpace(document.Content) == "" {
+			// index pages, only crawled for their links
+			site.AddDocument(page.Level, document)
+			return true
+		}
+
+		output := this.AnalyzeText(document)
+		if output == nil {
+			site.AddFailure(page.URL, page.Level, &models.ErrorEntity{Kind: "analysis", Url: page.URL, Message: "analysis failed"})
+			return true
+		}
+		site.AddDocument(page.Level, output)
+		return true
+	})
+	if err != nil {
+		site.SetError(nlp.CrawlErrorEntity(site.Url, err))
+	}
+	return site
+}
+
+func (this *Analyzer) SenseInfo(id string) *models.SenseEntity {
+	return this.context.Engine.Current().SenseInfo(id)
+}
+
+func (this *Analyzer) WordNet() *wordnet.WN {
+	return this.context.Engine.Current().WordNet
+}
+
+// AddLexiconEntry adds an entry to the user dictionary and saves it. It
+// returns false if the form already had that lemma and tag.
+func (this *Analyzer) AddLexiconEntry(form string, lemma string, tag string) (bool, error) {
+	return this.context.Engine.AddLexiconEntry(form, lemma, tag)
+}
+
+// RemoveLexiconEntry removes the user entries of a form with the given lemma
+// and tag (any of them when empty) and saves the user dictionary.
+func (this *Analyzer) RemoveLexiconEntry(form string, lemma string, tag string) (int, error) {
+	return this.context.Engine.RemoveLexiconEntry(form, lemma, tag)
+}
+
+// Dictionary returns the dictionary, to look up its entries. Edit it with
+// AddLexiconEntry and RemoveLexiconEntry, which are safe during a reload.
+func (this *Analyzer) Dictionary() *nlp.Dictionary {
+	return this.context.Engine.Current().Dictionary()
+}
+
+// Reload loads the linguistic data again, keeping the current data if it
+// fails.
+func (this *Analyzer) Reload() error {
+	return this.context.Engine.Reload()
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const CONLLU_EMPTY = "_"
+
+// ToCoNLLU serializes the document in CoNLL-U format, with a newpar comment
+// at the start of every paragraph. FreeLing multiwords
+// (e.g. New_York) and contractions split into several words sharing the same
+// span (e.g. del -> de el) are written as multiword token range lines
+// followed by their words.
+func (this *DocumentEntity) ToCoNLLU() string {
+	var buffer bytes.Buffer
+	if this.sentences == nil {
+		return ""
+	}
+
+	sid := 1
+	paragraph := 0
+	for s := this.sentences.Front(); s != nil; s = s.Next() {
+		se := s.Value.(*SentenceEntity)
+		se.writeSentence(&buffer, sid, se.paragraph != paragraph)
+		paragraph = se.paragraph
+		sid++
+	}
+	return buffer.String()
+}
+
+// ToCoNLLU serializes the sentence in CoNLL-U format with the given sentence
+// id, for sentences analyzed one at a time. newpar marks the first sentence
+// of a paragraph.
+func (this *SentenceEntity) ToCoNLLU(sid int, newpar bool) string {
+	var buffer bytes.Buffer
+	this.writeSentence(&buffer, sid, newpar)
+	return buffer.String()
+}
+
+func (this *SentenceEntity) writeSentence(buffer *bytes.Buffer, sid int, newpar bool) {
+	if newpar && this.paragraph > 0 {
+		buffer.WriteString("# newpar id = p" + strconv.Itoa(this.paragraph) + "\n")
+	}
+	buffer.WriteString("# sent_id = " + strconv.Itoa(sid) + "\n")
+	text := this.text
+	if text == "" {
+		text = this.body
+	}
+	buffer.WriteString("# text = " + strings.Replace(text, "\n", " ", -1) + "\n")
+	this.writeCoNLLU(buffer)
+	buffer.WriteString("\n")
+}
+
+func (this *SentenceEntity) writeCoNLLU(buffer *bytes.Buffer) {
+	tokens := make([]*TokenEntity, 0, this.tokens.Len())
+	for t := this.tokens.Front(); t != nil; t = t.Next() {
+		tokens = append(tokens, t.Value.(*TokenEntity))
+	}
+
+	id := 1
+	for i := 0; i < len(tokens); {
+		te := tokens[i]
+
+		// words of a split contraction share the span of the original token
+		j := i + 1
+		for j < len(tokens) && te.finish > te.start && tokens[j].start == te.start && tokens[j].finish == te.finish {
+			j++
+		}
+
+		if j > i+1 {
+			last := id + j - i - 1
+			this.writeRange(buffer, id, last, this.Surface(te), te)
+			for _, word := range tokens[i:j] {
+				writeWord(buffer, id, word.base, word.lemma, word, "")
+				id++
+			}
+		} else if len(te.components) > 0 {
+			last := id + len(te.components) - 1
+			this.writeRange(buffer, id, last, this.Surface(te), te)
+			for _, c := range te.components {
+				writeWord(buffer, id, c.base, strings.ToLower(c.base), te, "")
+				id++
+			}
+		} else {
+			writeWord(buffer, id, te.base, te.lemma, te, this.misc(te))
+			id++
+		}
+		i = j
+	}
+}
+
+func (this *SentenceEntity) writeRange(buffer *bytes.Buffer, first int, last int, form string, te *TokenEntity) {
+	misc := this.misc(te)
+	if misc == "" {
+		misc = CONLLU_EMPTY
+	}
+	buffer.WriteString(strconv.Itoa(first) + "-" + strconv.Itoa(last) + "\t" + form + strings.Repeat("\t"+CONLLU_EMPTY, 7) + "\t" + misc + "\n")
+}
+
+// writeWord writes a word line. Morphological columns are taken from te, so
+// the components of a multiword inherit UPOS, XPOS and FEATS.
+func writeWord(buffer *bytes.Buffer, id int, form string, lemma string, te *TokenEntity, misc string) {
+	fields := []string{
+		strconv.Itoa(id),
+		form,
+		lemma,
+		te.upos,
+		te.pos,
+		te.ufeats,
+		CONLLU_EMPTY,
+		CONLLU_EMPTY,
+		CONLLU_EMPTY,
+		misc,
+	}
+	for i, f := range fields {
+		if f == "" {
+			fields[i] = CONLLU_EMPTY
+		}
+	}
+	buffer.WriteString(strings.Join(fields, "\t") + "\n")
+}
+
+// Surface returns the text covered by the token span, or the token form
+// when the span does not fall inside the sentence text.
+func (this *SentenceEntity) Surface(te *TokenEntity) string {
+	start := te.start - this.offset
+	finish := te.finish - this.offset
+	if this.text == "" || start < 0 || finish > len(this.text) || start >= finish {
+		return strings.Replace(te.base, "_", " ", -1)
+	}
+	return this.text[start:finish]
+}
+
+// misc returns SpaceAfter=No when the token is immediately followed by a
+// non space character in the sentence text.
+func (this *SentenceEntity) misc(te *TokenEntity) string {
+	finish := te.finish - this.offset
+	if this.text == "" || te.finish <= te.start || finish < 0 || finish >= len(this.text) {
+		return ""
+	}
+	if !unicode.IsSpace(rune(this.text[finish])) {
+		return "SpaceAfter=No"
+	}
+	return ""
+}
@@ -4,6 +4,7 @@ import (
 	"container/list"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	uuid "github.com/nu7hatch/gouuid"
@@ -18,12 +19,19 @@ type DocumentEntity struct {
 	Description string `param:"description"`
 	Keywords    string `param:"keywords"`
 	Content     string `param:"content"`
+	HTML        string `param:"html"` // raw page, analyzed when there is no content
 	TopImage    string
 	Language    string   `param:"lang"`
-	Flags       []string `param:flags`
+	Flags       []string `param:"flags"`
+	Domains     []string `param:"domains"`
 	sentences   *list.List
 	Unknown     map[string]int64
 	Entities    *list.List
+	sentiment   *SentimentEntity
+	domains     []*DomainEntity
+	body        string
+	failure     *ErrorEntity
+	htmlOffsets []int
 }
 
 func NewDocumentEntity() *DocumentEntity {
@@ -37,6 +45,7 @@ func (this *DocumentEntity) Init() {
 	this.sentences = list.New()
 	this.Unknown = make(map[string]int64)
 	this.Status = ""
+	this.failure = nil
 }
 
 func (this *DocumentEntity) SexpString() string {
@@ -83,6 +92,10 @@ func (this *DocumentEntity) ToJSON() interface{} {
 		js["status"] = this.Status
 	}
 
+	if this.failure != nil {
+		js["error"] = this.failure
+	}
+
 	if this.sentences.Len() > 0 {
 		sentences := make([]interface{}, 0)
 		for s := this.sentences.Front(); s != nil; s = s.Next() {
@@ -93,14 +106,27 @@ func (this *DocumentEntity) ToJSON() interface{} {
 	}
 
 	if len(this.Unknown) > 0 {
+		names := make([]string, 0, len(this.Unknown))
+		for name := range this.Unknown {
+			names = append(names, name)
+		}
+		sort.Strings(names)
 		unknown := make([]interface{}, 0)
-		for name, frequency := range this.Unknown {
-			entity := NewUnknownEntity(name, frequency)
+		for _, name := range names {
+			entity := NewUnknownEntity(name, this.Unknown[name])
 			unknown = append(unknown, entity.ToJSON())
 		}
 		js["unknown"] = unknown
 	}
 
+	if this.sentiment != nil {
+		js["sentiment"] = this.sentiment
+	}
+
+	if len(this.domains) > 0 {
+		js["domains"] = this.domains
+	}
+
 	if this.Entities.Len() > 0 {
 		entities := make([]interface{}, 0)
 		for e := this.Entities.Front(); e != nil; e = e.Next() {
@@ -113,6 +139,32 @@ func (this *DocumentEntity) ToJSON() interface{} {
 	return js
 }
 
+func (this *DocumentEntity) GetId() string {
+	return this.id
+}
+func (this *DocumentEntity) GetTimestamp() int64 {
+	return this.timestamp
+}
+
+// SetBody keeps the text actually analyzed (title, description, keywords and
+// content), which token spans refer to.
+func (this *DocumentEntity) SetBody(body string) {
+	this.body = body
+}
+func (this *DocumentEntity) GetBody() string {
+	return this.body
+}
+
+// SetHTMLOffsets sets the position in the raw page of every byte of HTML,
+// when HTML was decoded from another charset, so the HTML spans of the
+// tokens point into the raw page (see nlp.DecodeHTML).
+func (this *DocumentEntity) SetHTMLOffsets(offsets []int) {
+	this.htmlOffsets = offsets
+}
+
+func (this *DocumentEntity) GetHTMLOffsets() []int {
+	return this.htmlOffsets
+}
 func (this *DocumentEntity) Sentences() *list.List {
 	return this.sentences
 }
@@ -125,10 +177,41 @@ func (this *DocumentEntity) AddSentenceEntity(se *SentenceEntity) {
 func (this *DocumentEntity) AddUnknownEntity(name string, frequency int64) {
 	this.Unknown[name] = frequency
 }
+func (this *DocumentEntity) SetSentiment(sentiment *SentimentEntity) {
+	this.sentiment = sentiment
+}
+func (this *DocumentEntity) GetSentiment() *SentimentEntity {
+	return this.sentiment
+}
+func (this *DocumentEntity) SetDomains(domains []*DomainEntity) {
+	this.domains = domains
+}
+func (this *DocumentEntity) GetDomains() []*DomainEntity {
+	return this.domains
+}
+
+// SetError records why the document could not be analyzed, such as a page
+// that could not be crawled.
+func (this *DocumentEntity) SetError(failure *ErrorEntity) {
+	this.failure = failure
+}
+func (this *DocumentEntity) GetError() *ErrorEntity {
+	return this.failure
+}
+func (this *DocumentEntity) HasFlag(flag string) bool {
+	for _, f := range this.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
 func (this *DocumentEntity) String() string {
 	return this.Url
 }
 
+const FLAG_ONTOLOGY = "ontology"
+
 const (
 	CLASS_PERSON = 0 << iota
 	CLASS_ORGANIZATION
@@ -150,6 +233,19 @@ type TokenEntity struct {
 	weight     float64
 	sense      int
 	annotation []*Annotation
+	synset     *SynsetEntity
+	senses     []*SenseEntity
+	shortTag   string
+	features   map[string]string
+	upos       string
+	ufeats     string
+	start      int
+	finish     int
+	htmlStart  int
+	htmlFinish int
+	components []*TokenEntity
+	analyses   []*AnalysisEntity
+	inDict     bool
 }
 
 type Annotation struct {
@@ -158,6 +254,15 @@ type Annotation struct {
 	Gloss string   `json:"glossary"`
 }
 
+type SynsetEntity struct {
+	Wnid     string  `json:"wnid"`
+	Domain   string  `json:"domain"`
+	Positive float64 `json:"positive"`
+	Negative float64 `json:"negative"`
+	Score    int     `json:"score"`
+	Gloss    string  `json:"gloss"`
+}
+
 func NewTokenEntity(base string, lemma string, pos string, prob float64, annotation []*Annotation) *TokenEntity {
 	return &TokenEntity{
 		base:       base,
@@ -176,15 +281,184 @@ func (this *TokenEntity) ToJSON() interface{} {
 	js["pos"] = this.pos
 	js["prob"] = this.prob
 	js["annotation"] = this.annotation
+	if this.synset != nil {
+		js["synset"] = this.synset
+	}
+	if len(this.senses) > 0 {
+		js["senses"] = this.senses
+	}
+	if this.upos != "" {
+		js["short_tag"] = this.shortTag
+		js["features"] = this.features
+		js["upos"] = this.upos
+		js["ufeats"] = this.ufeats
+	}
+	if this.htmlFinish > this.htmlStart {
+		js["html_start"] = this.htmlStart
+		js["html_finish"] = this.htmlFinish
+	}
 	return js
 }
 
+func (this *TokenEntity) SetMorphology(shortTag string, features map[string]string, upos string, ufeats string) {
+	this.shortTag = shortTag
+	this.features = features
+	this.upos = upos
+	this.ufeats = ufeats
+}
+
+func (this *TokenEntity) SetSpan(start int, finish int) {
+	this.start = start
+	this.finish = finish
+}
+
+func (this *TokenEntity) GetSpanStart() int {
+	return this.start
+}
+
+func (this *TokenEntity) GetSpanFinish() int {
+	return this.finish
+}
+
+// SetHTMLSpan sets the byte offsets of the token in the HTML page the text
+// was extracted from.
+func (this *TokenEntity) SetHTMLSpan(start int, finish int) {
+	this.htmlStart = start
+	this.htmlFinish = finish
+}
+
+func (this *TokenEntity) GetHTMLSpanStart() int {
+	return this.htmlStart
+}
+
+func (this *TokenEntity) GetHTMLSpanFinish() int {
+	return this.htmlFinish
+}
+
+// SetInDict records whether the form was found in the dictionary, as
+// opposed to guessed.
+func (this *TokenEntity) SetInDict(inDict bool) {
+	this.inDict = inDict
+}
+
+func (this *TokenEntity) IsInDict() bool {
+	return this.inDict
+}
+
+// AddComponent adds one of the words a multiword token was built from.
+func (this *TokenEntity) AddComponent(te *TokenEntity) {
+	this.components = append(this.components, te)
+}
+
+func (this *TokenEntity) GetComponents() []*TokenEntity {
+	return this.components
+}
+
+func (this *TokenEntity) GetShortTag() string {
+	return this.shortTag
+}
+
+func (this *TokenEntity) GetFeatures() map[string]string {
+	return this.features
+}
+
+func (this *TokenEntity) GetUPOS() string {
+	return this.upos
+}
+
+func (this *TokenEntity) GetUFeats() string {
+	return this.ufeats
+}
+
+func (this *TokenEntity) GetBase() string {
+	return this.base
+}
+
+func (this *TokenEntity) GetLemma() string {
+	return this.lemma
+}
+
+func (this *TokenEntity) GetPos() string {
+	return this.pos
+}
+
+func (this *TokenEntity) GetProb() float64 {
+	return this.prob
+}
+
+func (this *TokenEntity) AddAnalysis(analysis *AnalysisEntity) {
+	this.analyses = append(this.analyses, analysis)
+}
+
+func (this *TokenEntity) GetAnalyses() []*AnalysisEntity {
+	return this.analyses
+}
+
+func (this *TokenEntity) AddSense(sense *SenseEntity) {
+	this.senses = append(this.senses, sense)
+}
+
+func (this *TokenEntity) GetSenses() []*SenseEntity {
+	return this.senses
+}
+
+func (this *TokenEntity) SetSynset(synset *SynsetEntity) {
+	this.synset = synset
+}
+
+func (this *TokenEntity) GetSynset() *SynsetEntity {
+	return this.synset
+}
+
+// AnalysisEntity is one of the morphological analyses of a token; Selected
+// marks the ones chosen by the tagger.
+type AnalysisEntity struct {
+	Lemma    string  `json:"lemma"`
+	Tag      string  `json:"tag"`
+	Prob     float64 `json:"prob"`
+	Selected bool    `json:"selected"`
+}
+
+// LexiconEntry is a form, lemma and tag entry of the dictionary; User marks
+// the ones added at runtime.
+type LexiconEntry struct {
+	Form  string `json:"form"`
+	Lemma string `json:"lemma"`
+	Tag   string `json:"tag"`
+	User  bool   `json:"user"`
+}
+
+// ParseTreeEntity is a node of the shallow parse tree. Leaves refer to the
+// sentence token with index Word.
+type ParseTreeEntity struct {
+	Label    string             `json:"label,omitempty"`
+	Head     bool               `json:"head"`
+	Word     int                `json:"word"`
+	Children []*ParseTreeEntity `json:"children,omitempty"`
+}
+
+type SenseEntity struct {
+	Id      string   `json:"id"`
+	Rank    float64  `json:"rank"`
+	Words   []string `json:"words,omitempty"`
+	Parents []string `json:"parents,omitempty"`
+	SemFile string   `json:"semfile,omitempty"`
+	Tonto   []string `json:"tonto,omitempty"`
+	Sumo    string   `json:"sumo,omitempty"`
+	Cyc     string   `json:"cyc,omitempty"`
+}
+
 type SentenceEntity struct {
-	body     string
-	tokens   *list.List
-	weight   float64
-	sentence interface{}
-	wdws     *list.List
+	body      string
+	text      string
+	offset    int
+	paragraph int
+	tokens    *list.List
+	weight    float64
+	sentence  interface{}
+	wdws      *list.List
+	sentiment *SentimentEntity
+	tree      *ParseTreeEntity
 }
 
 func NewSentenceEntity() *SentenceEntity {
@@ -207,6 +481,12 @@ func (this *SentenceEntity) ToJSON() interface{} {
 		}
 		js["tokens"] = tokens
 	}
+	if this.paragraph > 0 {
+		js["paragraph"] = this.paragraph
+	}
+	if this.sentiment != nil {
+		js["sentiment"] = this.sentiment
+	}
 	return js
 }
 
@@ -214,9 +494,45 @@ func (this *SentenceEntity) AddTokenEntity(te *TokenEntity) {
 	this.tokens.PushBack(te)
 }
 
+func (this *SentenceEntity) Tokens() *list.List {
+	return this.tokens
+}
+
+func (this *SentenceEntity) GetBody() string {
+	return this.body
+}
+
+func (this *SentenceEntity) SetTree(tree *ParseTreeEntity) {
+	this.tree = tree
+}
+
+func (this *SentenceEntity) GetTree() *ParseTreeEntity {
+	return this.tree
+}
+
 func (this *SentenceEntity) SetBody(body string) {
 	this.body = body
 }
+
+// SetText keeps the sentence as it appears in the analyzed text, together
+// with the offset of its first character, so token spans can be mapped back.
+func (this *SentenceEntity) SetText(text string, offset int) {
+	this.text = text
+	this.offset = offset
+}
+
+func (this *SentenceEntity) GetText() string {
+	return this.text
+}
+
+// SetParagraph sets the number of the paragraph of the sentence in the
+// text, from 1.
+func (this *SentenceEntity) SetParagraph(paragraph int) {
+	this.paragraph = paragraph
+}
+func (this *SentenceEntity) GetParagraph() int {
+	return this.paragraph
+}
 func (this *SentenceEntity) SetSentence(sentence interface{}) {
 	this.sentence = sentence
 }
@@ -225,6 +541,21 @@ func (this *SentenceEntity) GetSentence() interface{} {
 	return this.sentence
 }
 
+func (this *SentenceEntity) SetSentiment(sentiment *SentimentEntity) {
+	this.sentiment = sentiment
+}
+
+func (this *SentenceEntity) GetSentiment() *SentimentEntity {
+	return this.sentiment
+}
+
+type SentimentEntity struct {
+	Positive float64 `json:"positive"`
+	Negative float64 `json:"negative"`
+	Score    float64 `json:"score"`
+	Polarity string  `json:"polarity"`
+}
+
 type Entity struct {
 	model string
 	score float64
@@ -255,6 +586,27 @@ func (this *Entity) GetValue() string {
 	return this.value
 }
 
+func (this *Entity) GetModel() string {
+	return this.model
+}
+
+func (this *Entity) GetScore() float64 {
+	return this.score
+}
+
+type DomainEntity struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+	Count  int     `json:"count"`
+}
+
+type ErrorEntity struct {
+	Kind    string `json:"kind"`
+	Url     string `json:"url,omitempty"`
+	Status  int    `json:"status,omitempty"`
+	Message string `json:"message"`
+}
+
 type UnknownEntity struct {
 	name      string
 	frequency int64
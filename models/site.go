@@ -0,0 +1,190 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	uuid "github.com/nu7hatch/gouuid"
+)
+
+// SiteEntity is the analysis of the pages of a site crawled from Url, with
+// the entities and unknown words of all of them.
+type SiteEntity struct {
+	id        string
+	timestamp int64
+	Url       string   `param:"url"`
+	MaxPages  int      `param:"pages"`  // the crawler limit when 0
+	MaxLevels int      `param:"levels"` // the crawler limit when 0
+	Language  string   `param:"lang"`
+	Flags     []string `param:"flags"`
+	Domains   []string `param:"domains"`
+	pages     []*PageEntity
+	entities  map[string]*CountEntity
+	unknown   map[string]*CountEntity
+	failure   *ErrorEntity
+}
+
+// PageEntity is a page of a crawled site.
+type PageEntity struct {
+	Url       string       `json:"url"`
+	Level     int          `json:"level"`
+	Title     string       `json:"title,omitempty"`
+	Sentences int          `json:"sentences"`
+	Error     *ErrorEntity `json:"error,omitempty"`
+}
+
+// CountEntity is an entity or unknown word of a site, with its occurrences
+// and the number of pages it occurs in.
+type CountEntity struct {
+	Name      string `json:"name"`
+	Type      string `json:"type,omitempty"`
+	Frequency int64  `json:"frequency"`
+	Pages     int    `json:"pages"`
+}
+
+func NewSiteEntity(url string) *SiteEntity {
+	return &SiteEntity{Url: url}
+}
+
+func (this *SiteEntity) Init() {
+	u4, _ := uuid.NewV4()
+	this.id = u4.String()
+	this.timestamp = time.Now().UnixNano()
+	this.pages = make([]*PageEntity, 0)
+	this.entities = make(map[string]*CountEntity)
+	this.unknown = make(map[string]*CountEntity)
+	this.failure = nil
+}
+
+// AddDocument adds an analyzed page, reached following level links from the
+// seed page.
+func (this *SiteEntity) AddDocument(level int, document *DocumentEntity) {
+	page := &PageEntity{
+		Url:   document.Url,
+		Level: level,
+		Title: document.Title,
+		Error: document.GetError(),
+	}
+	if document.Sentences() != nil {
+		page.Sentences = document.Sentences().Len()
+	}
+	this.pages = append(this.pages, page)
+
+	// entity mentions in the page
+	mentions := make(map[string]int64)
+	if document.Entities != nil {
+		for e := document.Entities.Front(); e != nil; e = e.Next() {
+			entity := e.Value.(*Entity)
+			key := entity.GetModel() + "\t" + entity.GetValue()
+			if _, ok := this.entities[key]; !ok {
+				this.entities[key] = &CountEntity{Name: entity.GetValue(), Type: entity.GetModel()}
+			}
+			mentions[key]++
+		}
+	}
+	for key, frequency := range mentions {
+		this.entities[key].Frequency += frequency
+		this.entities[key].Pages++
+	}
+
+	for name, frequency := range document.Unknown {
+		unknown, ok := this.unknown[name]
+		if !ok {
+			unknown = &CountEntity{Name: name}
+			this.unknown[name] = unknown
+		}
+		unknown.Frequency += frequency
+		unknown.Pages++
+	}
+}
+
+// AddFailure adds a page that could not be crawled.
+func (this *SiteEntity) AddFailure(url string, level int, failure *ErrorEntity) {
+	this.pages = append(this.pages, &PageEntity{Url: url, Level: level, Error: failure})
+}
+
+func (this *SiteEntity) GetId() string {
+	return this.id
+}
+func (this *SiteEntity) GetTimestamp() int64 {
+	return this.timestamp
+}
+func (this *SiteEntity) GetPages() []*PageEntity {
+	return this.pages
+}
+
+// SetError records why the site could not be crawled at all.
+func (this *SiteEntity) SetError(failure *ErrorEntity) {
+	this.failure = failure
+}
+func (this *SiteEntity) GetError() *ErrorEntity {
+	return this.failure
+}
+
+// GetEntities returns the entities of the site, the most frequent first.
+func (this *SiteEntity) GetEntities() []*CountEntity {
+	return sortCounts(this.entities)
+}
+
+// GetUnknown returns the unknown words of the site, the most frequent first.
+func (this *SiteEntity) GetUnknown() []*CountEntity {
+	return sortCounts(this.unknown)
+}
+
+func (this *SiteEntity) ToJSON() interface{} {
+	js := make(map[string]interface{})
+	if this.id != "" {
+		js["id"] = this.id
+	}
+
+	if this.timestamp > 0 {
+		js["timestamp"] = this.timestamp
+	}
+
+	if this.Url != "" {
+		js["url"] = this.Url
+	}
+
+	js["pages"] = this.pages
+
+	if len(this.entities) > 0 {
+		js["entities"] = this.GetEntities()
+	}
+
+	if len(this.unknown) > 0 {
+		js["unknown"] = this.GetUnknown()
+	}
+
+	if this.failure != nil {
+		js["error"] = this.failure
+	}
+
+	return js
+}
+
+func (this *SiteEntity) String() string {
+	return this.Url
+}
+
+// sortCounts sorts by decreasing frequency and number of pages, then by
+// name.
+func sortCounts(counts map[string]*CountEntity) []*CountEntity {
+	sorted := make([]*CountEntity, 0, len(counts))
+	for _, count := range counts {
+		sorted = append(sorted, count)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Frequency != b.Frequency {
+			return a.Frequency > b.Frequency
+		}
+		if a.Pages != b.Pages {
+			return a.Pages > b.Pages
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Type < b.Type
+	})
+	return sorted
+}
@@ -0,0 +1,456 @@
+package naf
+
+import (
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/advancedlogic/go-freeling/models"
+)
+
+const (
+	NAF_VERSION      = "v3"
+	PROCESSOR_NAME   = "go-freeling"
+	WORDNET_RESOURCE = "WordNet-3.0"
+	ILI_PREFIX       = "ili-30-"
+
+	TERM_OPEN  = "open"
+	TERM_CLOSE = "close"
+
+	ENTITY_MISC = "MISC"
+)
+
+// NAF part of speech for Universal Dependencies ones.
+var nafPOS = map[string]string{
+	"NOUN":  "N",
+	"PROPN": "R",
+	"ADJ":   "G",
+	"VERB":  "V",
+	"AUX":   "V",
+	"ADP":   "P",
+	"ADV":   "A",
+	"CCONJ": "C",
+	"SCONJ": "C",
+	"DET":   "D",
+}
+
+var openPOS = map[string]bool{"N": true, "R": true, "G": true, "V": true, "A": true}
+
+type NAF struct {
+	XMLName      xml.Name      `xml:"NAF"`
+	Lang         string        `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Version      string        `xml:"version,attr,omitempty"`
+	Header       *Header       `xml:"nafHeader"`
+	Raw          *Raw          `xml:"raw"`
+	Text         *Text         `xml:"text"`
+	Terms        *Terms        `xml:"terms"`
+	Entities     *Entities     `xml:"entities"`
+	Constituency *Constituency `xml:"constituency"`
+}
+
+type Header struct {
+	FileDesc   *FileDesc              `xml:"fileDesc"`
+	Public     *Public                `xml:"public"`
+	Processors []LinguisticProcessors `xml:"linguisticProcessors"`
+}
+
+type FileDesc struct {
+	Title        string `xml:"title,attr,omitempty"`
+	CreationTime string `xml:"creationtime,attr,omitempty"`
+}
+
+type Public struct {
+	PublicId string `xml:"publicId,attr,omitempty"`
+	Uri      string `xml:"uri,attr,omitempty"`
+}
+
+type LinguisticProcessors struct {
+	Layer string `xml:"layer,attr"`
+	Lp    []Lp   `xml:"lp"`
+}
+
+type Lp struct {
+	Name      string `xml:"name,attr"`
+	Timestamp string `xml:"timestamp,attr,omitempty"`
+}
+
+type Raw struct {
+	Text string `xml:",cdata"`
+}
+
+type Text struct {
+	Wf []Wf `xml:"wf"`
+}
+
+type Wf struct {
+	Id     string `xml:"id,attr"`
+	Sent   int    `xml:"sent,attr"`
+	Para   int    `xml:"para,attr,omitempty"`
+	Offset int    `xml:"offset,attr"`
+	Length int    `xml:"length,attr"`
+	Form   string `xml:",chardata"`
+}
+
+type Terms struct {
+	Term []Term `xml:"term"`
+}
+
+type Term struct {
+	Id                 string              `xml:"id,attr"`
+	Type               string              `xml:"type,attr,omitempty"`
+	Lemma              string              `xml:"lemma,attr"`
+	Pos                string              `xml:"pos,attr,omitempty"`
+	Morphofeat         string              `xml:"morphofeat,attr,omitempty"`
+	Span               Span                `xml:"span"`
+	ExternalReferences *ExternalReferences `xml:"externalReferences"`
+}
+
+type Span struct {
+	Target []Target `xml:"target"`
+}
+
+type Target struct {
+	Id string `xml:"id,attr"`
+}
+
+type ExternalReferences struct {
+	ExternalRef []ExternalRef `xml:"externalRef"`
+}
+
+type ExternalRef struct {
+	Resource   string  `xml:"resource,attr"`
+	Reference  string  `xml:"reference,attr"`
+	Confidence float64 `xml:"confidence,attr,omitempty"`
+}
+
+type Entities struct {
+	Entity []Entity `xml:"entity"`
+}
+
+type Entity struct {
+	Id         string     `xml:"id,attr"`
+	Type       string     `xml:"type,attr"`
+	References References `xml:"references"`
+}
+
+type References struct {
+	Span []Span `xml:"span"`
+}
+
+type Constituency struct {
+	Tree []Tree `xml:"tree"`
+}
+
+type Tree struct {
+	Nt   []Nt   `xml:"nt"`
+	T    []T    `xml:"t"`
+	Edge []Edge `xml:"edge"`
+}
+
+type Nt struct {
+	Id    string `xml:"id,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type T struct {
+	Id   string `xml:"id,attr"`
+	Span Span   `xml:"span"`
+}
+
+type Edge struct {
+	Id   string `xml:"id,attr"`
+	From string `xml:"from,attr"`
+	To   string `xml:"to,attr"`
+	Head string `xml:"head,attr,omitempty"`
+}
+
+// Write exports the document as NAF XML.
+func Write(out io.Writer, document *models.DocumentEntity) error {
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(FromDocument(document)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+// wordForm is a wf of a sentence and the terms built on it, used to find
+// entity mentions.
+type wordForm struct {
+	form  string
+	terms []string
+}
+
+type builder struct {
+	naf   *NAF
+	raw   string
+	chars []int
+	words [][]*wordForm
+	tags  map[string]string
+	// paragraph of the sentence being added
+	para int
+	// constituency ids, unique in the document
+	nts   int
+	ters  int
+	edges int
+}
+
+// FromDocument builds the NAF layers of an analyzed document. Offsets are
+// given in characters of the raw layer.
+func FromDocument(document *models.DocumentEntity) *NAF {
+	raw := document.GetBody()
+	if raw == "" {
+		raw = document.Content
+	}
+
+	this := &builder{
+		naf: &NAF{
+			Lang:         document.Language,
+			Version:      NAF_VERSION,
+			Raw:          &Raw{Text: raw},
+			Text:         &Text{},
+			Terms:        &Terms{},
+			Entities:     &Entities{},
+			Constituency: &Constituency{},
+		},
+		raw:   raw,
+		chars: charOffsets(raw),
+		tags:  make(map[string]string),
+	}
+
+	timestamp := time.Now()
+	if document.GetTimestamp() > 0 {
+		timestamp = time.Unix(0, document.GetTimestamp())
+	}
+	ts := timestamp.UTC().Format(time.RFC3339)
+	this.naf.Header = &Header{
+		FileDesc: &FileDesc{Title: document.Title, CreationTime: ts},
+		Public:   &Public{PublicId: document.GetId(), Uri: document.Url},
+	}
+	for _, layer := range []string{"text", "terms", "entities", "constituency"} {
+		this.naf.Header.Processors = append(this.naf.Header.Processors, LinguisticProcessors{
+			Layer: layer,
+			Lp:    []Lp{{Name: PROCESSOR_NAME, Timestamp: ts}},
+		})
+	}
+
+	if document.Sentences() != nil {
+		sent := 1
+		for s := document.Sentences().Front(); s != nil; s = s.Next() {
+			this.addSentence(sent, s.Value.(*models.SentenceEntity))
+			sent++
+		}
+	}
+
+	if document.Entities != nil {
+		for e := document.Entities.Front(); e != nil; e = e.Next() {
+			entity := e.Value.(*models.Entity)
+			this.addEntity(entity.GetModel(), entity.GetValue())
+		}
+	}
+	names := make([]string, 0, len(document.Unknown))
+	for name := range document.Unknown {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		this.addEntity(ENTITY_MISC, name)
+	}
+
+	return this.naf
+}
+
+// charOffsets maps every byte offset of text to its character offset.
+func charOffsets(text string) []int {
+	chars := make([]int, len(text)+1)
+	n := 0
+	for i := 0; i < len(text); i++ {
+		chars[i] = n
+		if i+1 == len(text) || utf8.RuneStart(text[i+1]) {
+			n++
+		}
+	}
+	chars[len(text)] = n
+	return chars
+}
+
+func (this *builder) addWf(sent int, form string, start int, finish int) string {
+	id := "w" + strconv.Itoa(len(this.naf.Text.Wf)+1)
+	wf := Wf{Id: id, Sent: sent, Para: this.para, Form: form, Length: utf8.RuneCountInString(form)}
+	if start >= 0 && start < finish && finish <= len(this.raw) {
+		wf.Offset = this.chars[start]
+		wf.Length = this.chars[finish] - this.chars[start]
+	}
+	this.naf.Text.Wf = append(this.naf.Text.Wf, wf)
+	this.words[sent-1] = append(this.words[sent-1], &wordForm{form: form})
+	return id
+}
+
+func (this *builder) addTerm(sent int, te *models.TokenEntity, wfs []string) string {
+	id := "t" + strconv.Itoa(len(this.naf.Terms.Term)+1)
+	pos, ok := nafPOS[te.GetUPOS()]
+	if !ok {
+		pos = "O"
+	}
+	term := Term{
+		Id:         id,
+		Type:       TERM_CLOSE,
+		Lemma:      te.GetLemma(),
+		Pos:        pos,
+		Morphofeat: te.GetPos(),
+	}
+	if openPOS[pos] {
+		term.Type = TERM_OPEN
+	}
+	for _, wf := range wfs {
+		term.Span.Target = append(term.Span.Target, Target{Id: wf})
+	}
+
+	refs := make([]ExternalRef, 0)
+	for _, sense := range te.GetSenses() {
+		refs = append(refs, ExternalRef{Resource: WORDNET_RESOURCE, Reference: ILI_PREFIX + sense.Id, Confidence: sense.Rank})
+	}
+	if len(refs) == 0 && te.GetSynset() != nil {
+		refs = append(refs, ExternalRef{Resource: WORDNET_RESOURCE, Reference: ILI_PREFIX + te.GetSynset().Wnid})
+	}
+	if len(refs) > 0 {
+		term.ExternalReferences = &ExternalReferences{ExternalRef: refs}
+	}
+	this.naf.Terms.Term = append(this.naf.Terms.Term, term)
+	this.tags[id] = term.Morphofeat
+
+	words := this.words[sent-1]
+	for i := len(words) - len(wfs); i < len(words); i++ {
+		words[i].terms = append(words[i].terms, id)
+	}
+	return id
+}
+
+func (this *builder) addSentence(sent int, se *models.SentenceEntity) {
+	this.words = append(this.words, make([]*wordForm, 0))
+	this.para = se.GetParagraph()
+
+	tokens := make([]*models.TokenEntity, 0, se.Tokens().Len())
+	for t := se.Tokens().Front(); t != nil; t = t.Next() {
+		tokens = append(tokens, t.Value.(*models.TokenEntity))
+	}
+
+	terms := make([]string, len(tokens))
+	for i := 0; i < len(tokens); {
+		te := tokens[i]
+
+		// words of a split contraction share the span of the original token
+		j := i + 1
+		for j < len(tokens) && te.GetSpanFinish() > te.GetSpanStart() && tokens[j].GetSpanStart() == te.GetSpanStart() && tokens[j].GetSpanFinish() == te.GetSpanFinish() {
+			j++
+		}
+
+		if j > i+1 {
+			wf := this.addWf(sent, se.Surface(te), te.GetSpanStart(), te.GetSpanFinish())
+			for k := i; k < j; k++ {
+				terms[k] = this.addTerm(sent, tokens[k], []string{wf})
+			}
+		} else if components := te.GetComponents(); len(components) > 0 {
+			wfs := make([]string, 0, len(components))
+			for _, c := range components {
+				wfs = append(wfs, this.addWf(sent, c.GetBase(), c.GetSpanStart(), c.GetSpanFinish()))
+			}
+			terms[i] = this.addTerm(sent, te, wfs)
+		} else {
+			wf := this.addWf(sent, te.GetBase(), te.GetSpanStart(), te.GetSpanFinish())
+			terms[i] = this.addTerm(sent, te, []string{wf})
+		}
+		i = j
+	}
+
+	if tree := se.GetTree(); tree != nil {
+		t := Tree{}
+		this.addNode(&t, tree, terms)
+		this.naf.Constituency.Tree = append(this.naf.Constituency.Tree, t)
+	}
+}
+
+// addNode adds a parse tree node and returns the id of its nonterminal.
+// Leaves are written as a preterminal labelled with the leaf label (or the
+// term morphofeat) dominating the terminal.
+func (this *builder) addNode(t *Tree, node *models.ParseTreeEntity, terms []string) string {
+	this.nts++
+	id := "nter" + strconv.Itoa(this.nts)
+	t.Nt = append(t.Nt, Nt{Id: id, Label: node.Label})
+
+	if len(node.Children) == 0 {
+		if node.Word >= 0 && node.Word < len(terms) && terms[node.Word] != "" {
+			this.ters++
+			ter := "ter" + strconv.Itoa(this.ters)
+			t.T = append(t.T, T{Id: ter, Span: Span{Target: []Target{{Id: terms[node.Word]}}}})
+			this.addEdge(t, ter, id, false)
+			if node.Label == "" {
+				t.Nt[len(t.Nt)-1].Label = this.tags[terms[node.Word]]
+			}
+		}
+		return id
+	}
+
+	for _, child := range node.Children {
+		this.addEdge(t, this.addNode(t, child, terms), id, child.Head)
+	}
+	return id
+}
+
+func (this *builder) addEdge(t *Tree, from string, to string, head bool) {
+	this.edges++
+	edge := Edge{Id: "tre" + strconv.Itoa(this.edges), From: from, To: to}
+	if head {
+		edge.Head = "yes"
+	}
+	t.Edge = append(t.Edge, edge)
+}
+
+// addEntity adds an entity with a reference for every mention of its words
+// in the text layer.
+func (this *builder) addEntity(kind string, value string) {
+	words := strings.Fields(strings.Replace(value, "_", " ", -1))
+	if len(words) == 0 {
+		return
+	}
+
+	entity := Entity{Type: kind}
+	for _, sentence := range this.words {
+		for i := 0; i+len(words) <= len(sentence); i++ {
+			match := true
+			for k, word := range words {
+				if sentence[i+k].form != word {
+					match = false
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+			span := Span{}
+			seen := make(map[string]bool)
+			for _, wf := range sentence[i : i+len(words)] {
+				for _, term := range wf.terms {
+					if !seen[term] {
+						seen[term] = true
+						span.Target = append(span.Target, Target{Id: term})
+					}
+				}
+			}
+			entity.References.Span = append(entity.References.Span, span)
+		}
+	}
+
+	if len(entity.References.Span) > 0 {
+		entity.Id = "e" + strconv.Itoa(len(this.naf.Entities.Entity)+1)
+		this.naf.Entities.Entity = append(this.naf.Entities.Entity, entity)
+	}
+}
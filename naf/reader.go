@@ -0,0 +1,268 @@
+package naf
+
+import (
+	"container/list"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/advancedlogic/go-freeling/models"
+)
+
+// Universal Dependencies part of speech for NAF ones.
+var universalPOS = map[string]string{
+	"N": "NOUN",
+	"R": "PROPN",
+	"G": "ADJ",
+	"V": "VERB",
+	"P": "ADP",
+	"A": "ADV",
+	"C": "CCONJ",
+	"D": "DET",
+}
+
+// Read parses a NAF document.
+func Read(in io.Reader) (*models.DocumentEntity, error) {
+	naf := new(NAF)
+	if err := xml.NewDecoder(in).Decode(naf); err != nil {
+		return nil, err
+	}
+	return ToDocument(naf), nil
+}
+
+// ToDocument rebuilds an analyzed document out of the NAF layers, so it can
+// be re-ingested. Terms become tokens, grouped in sentences by the sent
+// attribute of their first wf.
+func ToDocument(naf *NAF) *models.DocumentEntity {
+	document := models.NewDocumentEntity()
+	document.Init()
+	document.Entities = list.New()
+	document.Language = naf.Lang
+
+	raw := ""
+	if naf.Raw != nil {
+		raw = naf.Raw.Text
+	}
+	document.Content = raw
+	document.SetBody(raw)
+	bytes := byteOffsets(raw)
+
+	if naf.Header != nil {
+		if naf.Header.FileDesc != nil {
+			document.Title = naf.Header.FileDesc.Title
+		}
+		if naf.Header.Public != nil {
+			document.Url = naf.Header.Public.Uri
+		}
+	}
+
+	wfs := make(map[string]*Wf)
+	if naf.Text != nil {
+		for i := range naf.Text.Wf {
+			wfs[naf.Text.Wf[i].Id] = &naf.Text.Wf[i]
+		}
+	}
+
+	// term id -> sentence entity and token index, to resolve trees
+	type position struct {
+		sentence *models.SentenceEntity
+		index    int
+	}
+	positions := make(map[string]position)
+	forms := make(map[string]string)
+
+	// words of a split contraction are terms sharing the same wf
+	shared := make(map[string]int)
+	if naf.Terms != nil {
+		for _, term := range naf.Terms.Term {
+			for _, target := range term.Span.Target {
+				shared[target.Id]++
+			}
+		}
+	}
+
+	sentences := make(map[int]*models.SentenceEntity)
+	order := make([]int, 0)
+	if naf.Terms != nil {
+		for _, term := range naf.Terms.Term {
+			words := make([]*Wf, 0, len(term.Span.Target))
+			for _, target := range term.Span.Target {
+				if wf, ok := wfs[target.Id]; ok {
+					words = append(words, wf)
+				}
+			}
+			if len(words) == 0 {
+				continue
+			}
+
+			base := joinForms(words, "_")
+			if len(words) == 1 && shared[words[0].Id] > 1 {
+				base = term.Lemma
+			}
+			te := models.NewTokenEntity(base, term.Lemma, term.Morphofeat, 0, nil)
+			te.SetSpan(charToByte(bytes, words[0].Offset), charToByte(bytes, words[len(words)-1].Offset+words[len(words)-1].Length))
+			if len(words) > 1 {
+				for _, wf := range words {
+					c := models.NewTokenEntity(wf.Form, wf.Form, term.Morphofeat, 0, nil)
+					c.SetSpan(charToByte(bytes, wf.Offset), charToByte(bytes, wf.Offset+wf.Length))
+					te.AddComponent(c)
+				}
+			}
+			if upos, ok := universalPOS[term.Pos]; ok {
+				te.SetMorphology("", nil, upos, "")
+			}
+			if term.ExternalReferences != nil {
+				for _, ref := range term.ExternalReferences.ExternalRef {
+					if ref.Resource == WORDNET_RESOURCE {
+						te.AddSense(&models.SenseEntity{
+							Id:   strings.TrimPrefix(ref.Reference, ILI_PREFIX),
+							Rank: ref.Confidence,
+						})
+					}
+				}
+			}
+
+			sent := words[0].Sent
+			se, ok := sentences[sent]
+			if !ok {
+				se = models.NewSentenceEntity()
+				se.SetParagraph(words[0].Para)
+				sentences[sent] = se
+				order = append(order, sent)
+			}
+			positions[term.Id] = position{se, se.Tokens().Len()}
+			forms[term.Id] = joinForms(words, " ")
+			se.AddTokenEntity(te)
+		}
+	}
+
+	for _, sent := range order {
+		se := sentences[sent]
+		bases := make([]string, 0, se.Tokens().Len())
+		for t := se.Tokens().Front(); t != nil; t = t.Next() {
+			bases = append(bases, t.Value.(*models.TokenEntity).GetBase())
+		}
+		se.SetBody(strings.Join(bases, " "))
+		if se.Tokens().Len() > 0 {
+			start := se.Tokens().Front().Value.(*models.TokenEntity).GetSpanStart()
+			finish := se.Tokens().Back().Value.(*models.TokenEntity).GetSpanFinish()
+			if start >= 0 && start <= finish && finish <= len(raw) {
+				se.SetText(raw[start:finish], start)
+			}
+		}
+		document.AddSentenceEntity(se)
+	}
+
+	if naf.Entities != nil {
+		for _, entity := range naf.Entities.Entity {
+			if len(entity.References.Span) == 0 {
+				continue
+			}
+			words := make([]string, 0)
+			for _, target := range entity.References.Span[0].Target {
+				words = append(words, forms[target.Id])
+			}
+			value := strings.Join(words, " ")
+			if entity.Type == ENTITY_MISC {
+				document.AddUnknownEntity(value, int64(len(entity.References.Span)))
+			} else {
+				document.Entities.PushBack(models.NewEntity(entity.Type, 1, value))
+			}
+		}
+	}
+
+	if naf.Constituency != nil {
+		for _, tree := range naf.Constituency.Tree {
+			if len(tree.T) == 0 || len(tree.T[0].Span.Target) == 0 {
+				continue
+			}
+			p, ok := positions[tree.T[0].Span.Target[0].Id]
+			if !ok {
+				continue
+			}
+			root := readTree(tree, func(term string) int {
+				if q, ok := positions[term]; ok && q.sentence == p.sentence {
+					return q.index
+				}
+				return -1
+			})
+			if root != nil {
+				p.sentence.SetTree(root)
+			}
+		}
+	}
+
+	return document
+}
+
+func joinForms(words []*Wf, sep string) string {
+	forms := make([]string, 0, len(words))
+	for _, wf := range words {
+		forms = append(forms, wf.Form)
+	}
+	return strings.Join(forms, sep)
+}
+
+// byteOffsets maps every character offset of text to its byte offset.
+func byteOffsets(text string) []int {
+	bytes := make([]int, 0, len(text)+1)
+	for i := range text {
+		bytes = append(bytes, i)
+	}
+	return append(bytes, len(text))
+}
+
+func charToByte(bytes []int, offset int) int {
+	if offset < 0 {
+		return 0
+	}
+	if offset >= len(bytes) {
+		return bytes[len(bytes)-1]
+	}
+	return bytes[offset]
+}
+
+// readTree rebuilds a parse tree out of its nonterminals, terminals and
+// edges. A preterminal dominating a terminal becomes a leaf referring to the
+// token index of the term. Ids are unique in the document, but edges are only
+// resolved against the nodes of their own tree, so files whose ids restart in
+// every tree are read as well. The root is the nonterminal without an edge to
+// a parent.
+func readTree(tree Tree, index func(string) int) *models.ParseTreeEntity {
+	nodes := make(map[string]*models.ParseTreeEntity)
+	for _, nt := range tree.Nt {
+		nodes[nt.Id] = &models.ParseTreeEntity{Label: nt.Label, Word: -1}
+	}
+	leaves := make(map[string]string)
+	for _, t := range tree.T {
+		if len(t.Span.Target) > 0 {
+			leaves[t.Id] = t.Span.Target[0].Id
+		}
+	}
+
+	hasParent := make(map[string]bool)
+	for _, edge := range tree.Edge {
+		parent, ok := nodes[edge.To]
+		if !ok {
+			continue
+		}
+		if term, ok := leaves[edge.From]; ok {
+			parent.Word = index(term)
+			continue
+		}
+		child, ok := nodes[edge.From]
+		if !ok {
+			continue
+		}
+		child.Head = edge.Head == "yes"
+		parent.Children = append(parent.Children, child)
+		hasParent[edge.From] = true
+	}
+
+	for _, nt := range tree.Nt {
+		if !hasParent[nt.Id] {
+			return nodes[nt.Id]
+		}
+	}
+	return nil
+}
@@ -1,19 +1,43 @@
 package net
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	gonet "net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	. "github.com/advancedlogic/go-freeling/lib"
 	"github.com/advancedlogic/go-freeling/models"
+	"github.com/advancedlogic/go-freeling/nlp"
 	. "github.com/advancedlogic/go-freeling/terminal"
+	"github.com/advancedlogic/go-freeling/wordnet"
 )
 
+// MAX_HTML_SIZE is the largest page accepted by HTMLHandler, unless the
+// http.max_html key of the configuration sets another one.
+const MAX_HTML_SIZE = 5 * 1024 * 1024
+
 type reqBody struct {
 	Content string
+	HTML    string
+	Domains []string
+	Flags   []string
+}
+
+type crawlBody struct {
+	Url     string
+	Pages   int
+	Levels  int
+	Domains []string
+	Flags   []string
 }
 
 type HttpServer struct {
@@ -32,6 +56,18 @@ func NewHttpServer(analyzer *Analyzer) *HttpServer {
 func (this *HttpServer) Listen() {
 	this.router.HandleFunc("/analyzer", this.URLHandler)
 	this.router.HandleFunc("/analyzer-api", this.APIHandler)
+	this.router.HandleFunc("/crawl", this.CrawlHandler)
+	this.router.HandleFunc("/sense/{id}", this.SenseHandler)
+	this.router.HandleFunc("/wordnet/synsets", this.WordNetLookupHandler)
+	this.router.HandleFunc("/wordnet/synset/{id}", this.WordNetSynsetHandler)
+	this.router.HandleFunc("/wordnet/synset/{id}/{relation}", this.WordNetRelationHandler)
+	this.router.HandleFunc("/wordnet/related", this.WordNetRelatedHandler)
+	this.router.HandleFunc("/wordnet/similarity", this.WordNetSimilarityHandler)
+	this.router.HandleFunc("/lexicon", this.LexiconListHandler).Methods("GET")
+	this.router.HandleFunc("/lexicon", this.LexiconAddHandler).Methods("POST")
+	this.router.HandleFunc("/lexicon/{form}", this.LexiconLookupHandler).Methods("GET")
+	this.router.HandleFunc("/lexicon/{form}", this.LexiconRemoveHandler).Methods("DELETE")
+	this.router.HandleFunc("/admin/reload", this.ReloadHandler).Methods("POST")
 	this.router.HandleFunc("/ping", this.PingHandler)
 
 	port := this.analyzer.Int64("http.port", 9999)
@@ -40,6 +76,10 @@ func (this *HttpServer) Listen() {
 }
 
 func (this *HttpServer) APIHandler(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/html") {
+		this.HTMLHandler(w, r)
+		return
+	}
 
 	decoder := json.NewDecoder(r.Body)
 	var body reqBody
@@ -51,8 +91,47 @@ func (this *HttpServer) APIHandler(w http.ResponseWriter, r *http.Request) {
 
 	document := new(models.DocumentEntity)
 	document.Content = body.Content
+	document.HTML = body.HTML
+	document.Domains = body.Domains
+	document.Flags = body.Flags
+
+	this.DocumentHandler(document, w, r)
+}
+
+// HTMLHandler analyzes the HTML page in the request body, decoded from the
+// charset of the Content-Type header or of the page. The HTML spans of the
+// tokens are byte offsets in the body as sent. Domains and flags are given as
+// query parameters.
+func (this *HttpServer) HTMLHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body := http.MaxBytesReader(w, r.Body, this.analyzer.Int64("http.max_html", MAX_HTML_SIZE))
+	raw, err := ioutil.ReadAll(body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, offsets, err := nlp.DecodeHTML(raw, r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := r.URL.Query()
+	document := new(models.DocumentEntity)
+	document.HTML = page
+	document.SetHTMLOffsets(offsets)
+	if domains := params.Get("domains"); domains != "" {
+		document.Domains = strings.Split(domains, ",")
+	}
+	if flags := params.Get("flags"); flags != "" {
+		document.Flags = strings.Split(flags, ",")
+	}
 
-	this.DocumentHandler(document, w)
+	this.DocumentHandler(document, w, r)
 }
 
 func (this *HttpServer) URLHandler(w http.ResponseWriter, r *http.Request) {
@@ -60,12 +139,34 @@ func (this *HttpServer) URLHandler(w http.ResponseWriter, r *http.Request) {
 	url := params.Get("url")
 	document := new(models.DocumentEntity)
 	document.Url = url
+	if domains := params.Get("domains"); domains != "" {
+		document.Domains = strings.Split(domains, ",")
+	}
+	if flags := params.Get("flags"); flags != "" {
+		document.Flags = strings.Split(flags, ",")
+	}
 
-	this.DocumentHandler(document, w)
+	this.DocumentHandler(document, w, r)
 }
 
-func (this *HttpServer) DocumentHandler(document *models.DocumentEntity, w http.ResponseWriter) {
+func (this *HttpServer) DocumentHandler(document *models.DocumentEntity, w http.ResponseWriter, r *http.Request) {
 	output := this.analyzer.AnalyzeText(document)
+	if output == nil {
+		http.Error(w, "analysis failed", http.StatusInternalServerError)
+		return
+	}
+	if failure := output.GetError(); failure != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(errorStatus(failure))
+		this.writeJSON(output.ToJSON(), w)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "conllu" || strings.Contains(r.Header.Get("Accept"), "conllu") {
+		w.Header().Set("Content-Type", "text/x-conllu; charset=utf-8")
+		w.Write([]byte(output.ToCoNLLU()))
+		return
+	}
 
 	js := output.ToJSON()
 	b, err := json.Marshal(js)
@@ -77,6 +178,267 @@ func (this *HttpServer) DocumentHandler(document *models.DocumentEntity, w http.
 	}
 }
 
+// CrawlHandler crawls a site and analyzes its pages. The seed url, pages and
+// levels limits, domains and flags are given as query parameters, or as json
+// when posted.
+func (this *HttpServer) CrawlHandler(w http.ResponseWriter, r *http.Request) {
+	site := new(models.SiteEntity)
+	if r.Method == "POST" {
+		defer r.Body.Close()
+		var body crawlBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		site.Url = body.Url
+		site.MaxPages = body.Pages
+		site.MaxLevels = body.Levels
+		site.Domains = body.Domains
+		site.Flags = body.Flags
+	} else {
+		params := r.URL.Query()
+		site.Url = params.Get("url")
+		site.MaxPages, _ = strconv.Atoi(params.Get("pages"))
+		site.MaxLevels, _ = strconv.Atoi(params.Get("levels"))
+		if domains := params.Get("domains"); domains != "" {
+			site.Domains = strings.Split(domains, ",")
+		}
+		if flags := params.Get("flags"); flags != "" {
+			site.Flags = strings.Split(flags, ",")
+		}
+	}
+
+	output := this.analyzer.AnalyzeSite(r.Context(), site)
+	w.Header().Set("Content-Type", "application/json")
+	if failure := output.GetError(); failure != nil {
+		w.WriteHeader(errorStatus(failure))
+	}
+	this.writeJSON(output.ToJSON(), w)
+}
+
+// errorStatus is the HTTP status answered for a document that could not be
+// analyzed.
+func errorStatus(failure *models.ErrorEntity) int {
+	switch failure.Kind {
+	case nlp.CRAWL_INVALID_URL:
+		return http.StatusBadRequest
+	case nlp.CRAWL_ROBOTS:
+		return http.StatusForbidden
+	case nlp.CRAWL_TIMEOUT:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+func (this *HttpServer) SenseHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	sense := this.analyzer.SenseInfo(id)
+	if sense == nil {
+		http.Error(w, fmt.Sprintf("sense %s not found", id), http.StatusNotFound)
+		return
+	}
+
+	this.writeJSON(sense, w)
+}
+
+// WordNetLookupHandler answers /wordnet/synsets?lemma=dog&pos=n
+func (this *HttpServer) WordNetLookupHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	this.writeJSON(this.analyzer.WordNet().Lookup(params.Get("lemma"), params.Get("pos")), w)
+}
+
+// WordNetSynsetHandler answers /wordnet/synset/02084071-n
+func (this *HttpServer) WordNetSynsetHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	synset := this.analyzer.WordNet().SynsetById(id)
+	if synset == nil {
+		http.Error(w, fmt.Sprintf("synset %s not found", id), http.StatusNotFound)
+		return
+	}
+	this.writeJSON(synset, w)
+}
+
+// WordNetRelationHandler answers /wordnet/synset/02084071-n/hypernym, with an
+// optional depth parameter to get the transitive closure.
+func (this *HttpServer) WordNetRelationHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	wn := this.analyzer.WordNet()
+	if wn.SynsetById(vars["id"]) == nil {
+		http.Error(w, fmt.Sprintf("synset %s not found", vars["id"]), http.StatusNotFound)
+		return
+	}
+
+	var result []*wordnet.WNSynset
+	if depth := r.URL.Query().Get("depth"); depth != "" {
+		d, err := strconv.Atoi(depth)
+		if err != nil || d < 0 {
+			http.Error(w, fmt.Sprintf("invalid depth %s", depth), http.StatusBadRequest)
+			return
+		}
+		result = wn.Closure(vars["id"], vars["relation"], d)
+	} else {
+		result = wn.Related(vars["id"], vars["relation"])
+	}
+	if result == nil {
+		http.Error(w, fmt.Sprintf("unknown relation %s", vars["relation"]), http.StatusBadRequest)
+		return
+	}
+	this.writeJSON(result, w)
+}
+
+// WordNetRelatedHandler answers /wordnet/related?lemma=car&pos=n&relation=hyponym
+func (this *HttpServer) WordNetRelatedHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	this.writeJSON(this.analyzer.WordNet().RelatedWords(params.Get("lemma"), params.Get("pos"), params.Get("relation")), w)
+}
+
+// WordNetSimilarityHandler answers /wordnet/similarity?a=02084071-n&b=02121620-n&measure=wup
+func (this *HttpServer) WordNetSimilarityHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	measure := params.Get("measure")
+	if measure == "" {
+		measure = wordnet.SIM_PATH
+	}
+	similarity, err := this.analyzer.WordNet().Similarity(params.Get("a"), params.Get("b"), measure)
+	if err == wordnet.ErrUnknownSynset {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	this.writeJSON(map[string]interface{}{
+		"a":          params.Get("a"),
+		"b":          params.Get("b"),
+		"measure":    measure,
+		"similarity": similarity,
+	}, w)
+}
+
+// dictionary returns the dictionary, answering 503 when the pipeline has
+// none.
+func (this *HttpServer) dictionary(w http.ResponseWriter) *nlp.Dictionary {
+	dictionary := this.analyzer.Dictionary()
+	if dictionary == nil {
+		http.Error(w, "no dictionary loaded", http.StatusServiceUnavailable)
+	}
+	return dictionary
+}
+
+// LexiconListHandler answers GET /lexicon with the user entries.
+func (this *HttpServer) LexiconListHandler(w http.ResponseWriter, r *http.Request) {
+	if dictionary := this.dictionary(w); dictionary != nil {
+		this.writeJSON(dictionary.UserEntries(), w)
+	}
+}
+
+// LexiconLookupHandler answers GET /lexicon/{form} with the base and user
+// entries of the form.
+func (this *HttpServer) LexiconLookupHandler(w http.ResponseWriter, r *http.Request) {
+	if dictionary := this.dictionary(w); dictionary != nil {
+		this.writeJSON(dictionary.Lookup(mux.Vars(r)["form"]), w)
+	}
+}
+
+// LexiconAddHandler answers POST /lexicon {"form":"gofreeling","lemma":"gofreeling","tag":"NP00000"}
+func (this *HttpServer) LexiconAddHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var entry models.LexiconEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	added, err := this.analyzer.AddLexiconEntry(entry.Form, entry.Lemma, entry.Tag)
+	if err == nlp.ErrInvalidEntry {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err == nlp.ErrNoDictionary {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dictionary := this.dictionary(w)
+	if dictionary == nil {
+		return
+	}
+	if added {
+		w.WriteHeader(http.StatusCreated)
+	}
+	this.writeJSON(dictionary.Lookup(entry.Form), w)
+}
+
+// LexiconRemoveHandler answers DELETE /lexicon/{form}?lemma=...&tag=...,
+// removing the matching user entries; lemma and tag are optional.
+func (this *HttpServer) LexiconRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	form := mux.Vars(r)["form"]
+	params := r.URL.Query()
+	removed, err := this.analyzer.RemoveLexiconEntry(form, params.Get("lemma"), params.Get("tag"))
+	if err == nlp.ErrNoDictionary {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if removed == 0 {
+		http.Error(w, fmt.Sprintf("no user entries for %s", form), http.StatusNotFound)
+		return
+	}
+	if dictionary := this.dictionary(w); dictionary != nil {
+		this.writeJSON(dictionary.Lookup(form), w)
+	}
+}
+
+// ReloadHandler answers POST /admin/reload, loading the linguistic data
+// again while requests keep being served with the current one.
+func (this *HttpServer) ReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if !this.admin(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	start := time.Now()
+	if err := this.analyzer.Reload(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		this.writeJSON(map[string]interface{}{
+			"status": "error",
+			"error":  err.Error(),
+		}, w)
+		return
+	}
+	this.writeJSON(map[string]interface{}{
+		"status":  "reloaded",
+		"elapsed": time.Since(start).String(),
+	}, w)
+}
+
+// admin tells whether a request may use the /admin endpoints: it must carry
+// the http.admin_token of the configuration as a bearer token or, when no
+// token is configured, come from the local host.
+func (this *HttpServer) admin(r *http.Request) bool {
+	token := this.analyzer.String("http.admin_token", "")
+	if token != "" {
+		return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) == 1
+	}
+	host, _, err := gonet.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := gonet.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (this *HttpServer) writeJSON(js interface{}, w http.ResponseWriter) {
+	b, err := json.Marshal(js)
+	if err != nil {
+		w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+	} else {
+		w.Write([]byte(fmt.Sprintf("%s\n", string(b))))
+	}
+}
+
 func (this *HttpServer) PingHandler(w http.ResponseWriter, r *http.Request) {
 	Infoln("pong")
 	w.Write([]byte("pong"))
@@ -0,0 +1,200 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	gonet "net"
+	"strings"
+	"time"
+
+	. "github.com/advancedlogic/go-freeling/lib"
+	"github.com/advancedlogic/go-freeling/models"
+	. "github.com/advancedlogic/go-freeling/terminal"
+	"github.com/advancedlogic/go-freeling/writer"
+)
+
+// Control messages of the FreeLing analyzer_client protocol. Every message
+// in both directions is terminated by a null byte.
+const (
+	SOCKET_RESET_STATS  = "RESET_STATS"
+	SOCKET_PRINT_STATS  = "PRINT_STATS"
+	SOCKET_FLUSH_BUFFER = "FLUSH_BUFFER"
+	SOCKET_READY        = "FL-SERVER-READY"
+)
+
+// SocketServer speaks the TCP protocol of the FreeLing analyzer server, so
+// existing analyzer_client tools can talk to go-freeling. Every connection
+// keeps its own splitter session, as a FreeLing server does: each message is
+// a line of the text, and a sentence is answered once the splitter closes
+// it, so sentences may span messages. FLUSH_BUFFER, and the end of the
+// connection, end and answer the pending sentence.
+type SocketServer struct {
+	analyzer *Analyzer
+	output   string
+	level    string
+}
+
+// socketSession is the state of a connection.
+type socketSession struct {
+	stream    *Stream
+	stats     *socketStats
+	sid       int
+	paragraph int
+}
+
+type socketStats struct {
+	words     int
+	sentences int
+	elapsed   time.Duration
+}
+
+func NewSocketServer(analyzer *Analyzer) *SocketServer {
+	instance := new(SocketServer)
+	instance.analyzer = analyzer
+	instance.output = analyzer.String("socket.output", "text")
+	instance.level = analyzer.Level()
+	if instance.level == "" {
+		instance.level = writer.LEVEL_TAGGED
+	}
+
+	return instance
+}
+
+func (this *SocketServer) Listen(port int64) error {
+	listener, err := gonet.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	Infof("Socket Server listening on port %d\n", port)
+	return this.serve(listener)
+}
+
+// serve accepts connections until the listener fails. Temporary errors (such
+// as running out of file descriptors) are retried after a growing delay, as
+// net/http does.
+func (this *SocketServer) serve(listener gonet.Listener) error {
+	defer listener.Close()
+	var delay time.Duration
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if e, ok := err.(gonet.Error); ok && e.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+				Errorln(err.Error())
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+		go this.handle(conn)
+	}
+}
+
+func (this *SocketServer) handle(conn gonet.Conn) {
+	defer conn.Close()
+	defer func() {
+		if r := recover(); r != nil {
+			Errorln(fmt.Sprintf("Socket analysis failed: %v", r))
+		}
+	}()
+	stream, err := this.analyzer.NewStream()
+	if err != nil {
+		Errorln(err.Error())
+		return
+	}
+	defer stream.Close()
+	session := &socketSession{stream: stream, stats: new(socketStats)}
+	reader := bufio.NewReader(conn)
+
+	for {
+		message, err := reader.ReadString(0)
+		if err != nil {
+			// the client may still read the end of a half closed connection
+			if response := this.flush(session); response != "" {
+				conn.Write([]byte(response + "\x00"))
+			}
+			return
+		}
+		message = strings.TrimSuffix(message, "\x00")
+
+		response := ""
+		switch message {
+		case SOCKET_RESET_STATS:
+			session.stats = new(socketStats)
+			response = SOCKET_READY
+		case SOCKET_PRINT_STATS:
+			response = session.stats.String()
+		case SOCKET_FLUSH_BUFFER:
+			response = this.flush(session)
+		default:
+			response = this.analyze(message, session)
+		}
+
+		if response == "" {
+			response = SOCKET_READY
+		}
+		if _, err := conn.Write([]byte(response + "\x00")); err != nil {
+			return
+		}
+	}
+}
+
+// analyze adds a message to the text of the connection, as a line, and
+// returns the sentences it completes.
+func (this *SocketServer) analyze(text string, session *socketSession) string {
+	start := time.Now()
+	sentences := session.stream.Write(text + "\n")
+	session.stats.elapsed += time.Since(start)
+	return this.format(sentences, session)
+}
+
+// flush returns the pending sentence of the connection.
+func (this *SocketServer) flush(session *socketSession) string {
+	start := time.Now()
+	sentences := session.stream.Flush()
+	session.stats.elapsed += time.Since(start)
+	return this.format(sentences, session)
+}
+
+// format writes sentences in the output format of the server.
+func (this *SocketServer) format(sentences []*models.SentenceEntity, session *socketSession) string {
+	var buffer bytes.Buffer
+	for _, se := range sentences {
+		session.sid++
+		session.stats.sentences++
+		session.stats.words += se.Tokens().Len()
+
+		switch this.output {
+		case "json":
+			b, err := json.Marshal(se.ToJSON())
+			if err != nil {
+				Errorln(err.Error())
+				continue
+			}
+			buffer.Write(b)
+			buffer.WriteString("\n")
+		case "conllu":
+			buffer.WriteString(se.ToCoNLLU(session.sid, se.GetParagraph() != session.paragraph))
+		default:
+			writer.NewWriter(this.level).WriteSentence(&buffer, se)
+		}
+		session.paragraph = se.GetParagraph()
+	}
+	return buffer.String()
+}
+
+func (this *socketStats) String() string {
+	speed := 0.0
+	if this.elapsed > 0 {
+		speed = float64(this.words) / this.elapsed.Seconds()
+	}
+	return fmt.Sprintf("Words: %d, sentences: %d, processing time: %.2f ms, words/second: %.0f",
+		this.words, this.sentences, float64(this.elapsed)/float64(time.Millisecond), speed)
+}
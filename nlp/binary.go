@@ -0,0 +1,136 @@
+package nlp
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+)
+
+// Compiled data files are the gob encoding of the structures built by the
+// loaders, written next to the text file they come from, with a header to
+// reject files of another format version or compiled from a different text
+// file or a different version of the files it refers to, such as a tagset.
+const (
+	BINARY_MAGIC   = "gofreeling-data"
+	BINARY_VERSION = 2
+	BINARY_SUFFIX  = ".bin"
+)
+
+const (
+	BINARY_DICTIONARY  = "dictionary"
+	BINARY_LOCUTIONS   = "locutions"
+	BINARY_PROBABILITY = "probability"
+	BINARY_HMM         = "hmm"
+)
+
+type binaryHeader struct {
+	Magic   string
+	Version int
+	Kind    string
+	Size    int64
+	ModTime int64
+	Depends []binaryStamp
+}
+
+// binaryStamp identifies the version of a file a compiled file depends on.
+type binaryStamp struct {
+	Name    string
+	Size    int64
+	ModTime int64
+}
+
+// changed tells if the file is not the one stamped.
+func (this binaryStamp) changed() bool {
+	info, err := os.Stat(this.Name)
+	return err != nil || info.Size() != this.Size || info.ModTime().UnixNano() != this.ModTime
+}
+
+// BinaryFile returns the name of the compiled version of a text data file.
+func BinaryFile(textFile string) string {
+	return textFile + BINARY_SUFFIX
+}
+
+// readBinary decodes the compiled version of textFile into data. It returns
+// false, so the caller parses the text file, when there is no compiled file,
+// it has another format version or kind, or the text file or any of the
+// files it depends on changed since it was compiled.
+func readBinary(textFile string, kind string, data interface{}) bool {
+	file, err := os.Open(BinaryFile(textFile))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(file)
+	var header binaryHeader
+	if err := decoder.Decode(&header); err != nil {
+		LOG.Warn("Ignoring unreadable compiled file " + BinaryFile(textFile))
+		return false
+	}
+	if header.Magic != BINARY_MAGIC || header.Version != BINARY_VERSION || header.Kind != kind {
+		LOG.Warn("Ignoring compiled file " + BinaryFile(textFile) + " of another version")
+		return false
+	}
+	if info, err := os.Stat(textFile); err == nil && (info.Size() != header.Size || info.ModTime().UnixNano() != header.ModTime) {
+		LOG.Warn("Ignoring stale compiled file " + BinaryFile(textFile))
+		return false
+	}
+	for _, depend := range header.Depends {
+		if depend.changed() {
+			LOG.Warn("Ignoring compiled file " + BinaryFile(textFile) + " stale with respect to " + depend.Name)
+			return false
+		}
+	}
+	if err := decoder.Decode(data); err != nil {
+		LOG.Warn("Ignoring unreadable compiled file " + BinaryFile(textFile))
+		return false
+	}
+	return true
+}
+
+// writeBinary writes data as the compiled version of textFile, replacing
+// the previous one once it is completely written. The current versions of
+// the depends files are stamped in the header too.
+func writeBinary(textFile string, kind string, data interface{}, depends ...string) error {
+	info, err := os.Stat(textFile)
+	if err != nil {
+		return err
+	}
+	header := binaryHeader{
+		Magic:   BINARY_MAGIC,
+		Version: BINARY_VERSION,
+		Kind:    kind,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Depends: make([]binaryStamp, 0, len(depends)),
+	}
+	for _, depend := range depends {
+		info, err := os.Stat(depend)
+		if err != nil {
+			return err
+		}
+		header.Depends = append(header.Depends, binaryStamp{depend, info.Size(), info.ModTime().UnixNano()})
+	}
+
+	name := BinaryFile(textFile)
+	file, err := os.Create(filepath.Join(filepath.Dir(name), "."+filepath.Base(name)+".tmp"))
+	if err != nil {
+		return err
+	}
+	encoder := gob.NewEncoder(file)
+	if err := encoder.Encode(header); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := encoder.Encode(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), name)
+}
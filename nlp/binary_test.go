@@ -0,0 +1,50 @@
+package nlp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestBinaryTagsetStale compiles the probabilities and the tagger and checks
+// that their compiled files are ignored once the tagset changes.
+func TestBinaryTagsetStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"probabilitats.dat", "tagger.dat", "tagset.dat"} {
+		data, err := ioutil.ReadFile(filepath.Join("testdata/en", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	probFile := dir + "/probabilitats.dat"
+	hmmFile := dir + "/tagger.dat"
+	if err := NewProbability(probFile, 0.001).Compile(probFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewHMMTagger(hmmFile, true, FORCE_TAGGER, 1).Compile(hmmFile); err != nil {
+		t.Fatal(err)
+	}
+	if !readBinary(probFile, BINARY_PROBABILITY, new(probabilityData)) || !readBinary(hmmFile, BINARY_HMM, new(hmmData)) {
+		t.Fatal("compiled files not read")
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(dir+"/tagset.dat", later, later); err != nil {
+		t.Fatal(err)
+	}
+	if readBinary(probFile, BINARY_PROBABILITY, new(probabilityData)) {
+		t.Error("compiled probabilities read after the tagset changed")
+	}
+	if readBinary(hmmFile, BINARY_HMM, new(hmmData)) {
+		t.Error("compiled tagger read after the tagset changed")
+	}
+}
@@ -0,0 +1,89 @@
+package nlp
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var concurrencyTexts = []string{
+	"The can rusts. The cat sits in front of the can.",
+	"The dog can run! A cat runs in New York.",
+	"The cat sits.\n\nThe can rusts on a mat. The dog can sit?",
+	"A dog sits in front of the cat. The run rusts.",
+}
+
+// newTestEngine loads the small English fixtures of testdata, without MITIE.
+func newTestEngine(workers int) *NLPEngine {
+	options := NewNLPOptions("testdata", "en", func() {})
+	options.Severity = ERROR
+	options.Workers = workers
+
+	maco := NewMacoOptions("en")
+	maco.SetDataFiles("", "", "testdata/en/dicc.src", "", "", "testdata/en/locucions.dat", "", "", "testdata/en/probabilitats.dat")
+
+	return &NLPEngine{
+		options:   options,
+		tokenizer: NewTokenizer("testdata/en/tokenizer.dat"),
+		splitter:  NewSplitter("testdata/en/splitter.dat"),
+		morfo:     NewMaco(maco),
+		tagger:    NewHMMTagger("testdata/en/tagger.dat", true, FORCE_TAGGER, 1),
+	}
+}
+
+// analyzeText runs the sentence level steps of Workflow and prints every
+// word as form/lemma/tag, one sentence per line.
+func analyzeText(engine *NLPEngine, text string) string {
+	tokens := list.New()
+	engine.tokenizer.Tokenize(text, 0, tokens)
+	sentences := list.New()
+	sid := engine.splitter.OpenSession()
+	engine.splitter.Split(sid, tokens, true, sentences)
+	engine.splitter.CloseSession(sid)
+	engine.analyzeSentences(sentences)
+
+	lines := make([]string, 0)
+	for ss := sentences.Front(); ss != nil; ss = ss.Next() {
+		words := make([]string, 0)
+		for ww := ss.Value.(*Sentence).Front(); ww != nil; ww = ww.Next() {
+			w := ww.Value.(*Word)
+			words = append(words, w.getForm()+"/"+w.getLemma(0)+"/"+w.getTag(0))
+		}
+		lines = append(lines, strings.Join(words, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TestConcurrentAnalysis analyzes the same texts sequentially and then from
+// several goroutines, sentences being analyzed in parallel too, and checks
+// that the results do not change. Run it with -race to catch data races.
+func TestConcurrentAnalysis(t *testing.T) {
+	engine := newTestEngine(4)
+
+	expected := make([]string, len(concurrencyTexts))
+	for i, text := range concurrencyTexts {
+		expected[i] = analyzeText(engine, text)
+	}
+	if !strings.Contains(expected[0], "in_front_of/in_front_of/IN") {
+		t.Fatalf("multiword not recognized: %s", expected[0])
+	}
+	if !strings.Contains(expected[1], "New_York/new_york/NP") {
+		t.Fatalf("multiword not recognized: %s", expected[1])
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for round := 0; round < 10; round++ {
+				i := (g + round) % len(concurrencyTexts)
+				if got := analyzeText(engine, concurrencyTexts[i]); got != expected[i] {
+					t.Errorf("text %d analyzed concurrently:\n%s\nexpected:\n%s", i, got, expected[i])
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,212 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	goose "github.com/advancedlogic/GoOse"
+	"golang.org/x/net/html"
+)
+
+// CrawledPage is a page reached by a crawl job: its article, or the error
+// that prevented fetching it.
+type CrawledPage struct {
+	URL string
+	// links followed from the seed page to reach it
+	Level   int
+	Article *goose.Article
+	Err     error
+}
+
+// CrawlJob crawls the pages of a site breadth first from a seed page,
+// following the links to the same host.
+type CrawlJob struct {
+	crawler *Crawler
+	seed    string
+	npages  int
+	nlevels int
+}
+
+// NewCrawlJob creates a job crawling from seed with the page and level limits
+// of the crawler.
+func (this *Crawler) NewCrawlJob(seed string) *CrawlJob {
+	return &CrawlJob{
+		crawler: this,
+		seed:    seed,
+		npages:  this.npages,
+		nlevels: this.nlevels,
+	}
+}
+
+// SetPages lowers the number of pages fetched. Zero or more than the crawler
+// allows keeps the crawler limit.
+func (this *CrawlJob) SetPages(npages int) {
+	if npages > 0 && npages < this.crawler.npages {
+		this.npages = npages
+	}
+}
+func (this *CrawlJob) GetPages() int { return this.npages }
+
+// SetLevels lowers the number of links followed from the seed page. Zero or
+// more than the crawler allows keeps the crawler limit.
+func (this *CrawlJob) SetLevels(nlevels int) {
+	if nlevels > 0 && nlevels < this.crawler.nlevels {
+		this.nlevels = nlevels
+	}
+}
+func (this *CrawlJob) GetLevels() int { return this.nlevels }
+
+// Run fetches the pages and calls visit on each of them, seed first, until
+// the page limit is reached, no link is left or visit returns false. Pages
+// that can not be fetched are visited with their error and count in the
+// limit. Requests to the site are separated by the crawler delay, or the
+// Crawl-delay of robots.txt when longer. A seed that is not an http or https
+// URL is returned as a *CrawlError. Once ctx is done, the crawl stops and
+// returns its error.
+func (this *CrawlJob) Run(ctx context.Context, visit func(page *CrawledPage) bool) error {
+	seed, err := url.Parse(this.seed)
+	if err != nil || (seed.Scheme != "http" && seed.Scheme != "https") || seed.Host == "" {
+		if err == nil {
+			err = errors.New("only absolute http and https URLs can be crawled")
+		}
+		return &CrawlError{Kind: CRAWL_INVALID_URL, URL: this.seed, Err: err}
+	}
+	seed.Fragment = ""
+
+	queue := []*CrawledPage{{URL: seed.String(), Level: 0}}
+	seen := map[string]bool{seed.String(): true}
+	pages := 0
+	var last time.Time
+
+	for len(queue) > 0 && pages < this.npages {
+		page := queue[0]
+		queue = queue[1:]
+		pages++
+
+		if !last.IsZero() {
+			if wait := this.delay(seed) - time.Since(last); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				}
+			}
+		}
+		content, finalURL, err := this.crawler.FetchContext(ctx, page.URL)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if crawlError, ok := err.(*CrawlError); ok && crawlError.Kind == CRAWL_ROBOTS {
+			// not requested, and only the seed is reported
+			if page.Level > 0 {
+				pages--
+				continue
+			}
+		} else {
+			last = time.Now()
+		}
+		if err != nil {
+			page.Err = err
+			if !visit(page) {
+				return nil
+			}
+			continue
+		}
+
+		final, err := url.Parse(finalURL)
+		if err != nil || !sameSite(final, seed) {
+			// redirected out of the site
+			continue
+		}
+		if page.Level < this.nlevels {
+			for _, link := range pageLinks(content, final) {
+				if !seen[link] {
+					seen[link] = true
+					queue = append(queue, &CrawledPage{URL: link, Level: page.Level + 1})
+				}
+			}
+		}
+
+		page.Article, page.Err = this.crawler.extract(page.URL, content, finalURL)
+		if !visit(page) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// delay is the time between two requests to the site.
+func (this *CrawlJob) delay(site *url.URL) time.Duration {
+	delay := time.Duration(this.crawler.delay) * time.Millisecond
+	if this.crawler.robots {
+		robots := time.Duration(this.crawler.robotsOf(site).delay * float64(time.Second))
+		if robots > delay {
+			delay = robots
+		}
+	}
+	return delay
+}
+
+// sameSite tells whether two URLs are on the same host, with or without www.
+func sameSite(a *url.URL, b *url.URL) bool {
+	host := func(u *url.URL) string {
+		return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	}
+	return host(a) == host(b)
+}
+
+// pageLinks returns the http and https links of a page to its own site,
+// without fragments and in page order. Links marked nofollow are skipped.
+func pageLinks(page string, base *url.URL) []string {
+	site := base
+	links := make([]string, 0)
+	z := html.NewTokenizer(strings.NewReader(page))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return links
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		name, hasAttr := z.TagName()
+		tag := string(name)
+		if (tag != "a" && tag != "base") || !hasAttr {
+			continue
+		}
+
+		href, rel := "", ""
+		for more := true; more; {
+			var key, value []byte
+			key, value, more = z.TagAttr()
+			switch string(key) {
+			case "href":
+				href = strings.TrimSpace(string(value))
+			case "rel":
+				rel = strings.ToLower(string(value))
+			}
+		}
+		ref, err := url.Parse(href)
+		if href == "" || err != nil {
+			continue
+		}
+		if tag == "base" {
+			base = base.ResolveReference(ref)
+			continue
+		}
+		if strings.Contains(rel, "nofollow") {
+			continue
+		}
+
+		link := base.ResolveReference(ref)
+		link.Fragment = ""
+		if (link.Scheme == "http" || link.Scheme == "https") && sameSite(link, site) {
+			links = append(links, link.String())
+		}
+	}
+}
@@ -1,33 +1,486 @@
 package nlp
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	goose "github.com/advancedlogic/GoOse"
+	"golang.org/x/net/html/charset"
+
+	"github.com/advancedlogic/go-freeling/models"
+)
+
+const (
+	CRAWLER_USER_AGENT    = "go-freeling/0.1 (+https://github.com/advancedlogic/go-freeling)"
+	CRAWLER_TIMEOUT       = 3000
+	CRAWLER_MAX_REDIRECTS = 5
+	CRAWLER_MAX_SIZE      = 5 * 1024 * 1024
+	CRAWLER_PAGES         = 20
+	CRAWLER_LEVELS        = 2
+	CRAWLER_DELAY         = 1000
+	// largest robots.txt read, as Google does
+	CRAWLER_MAX_ROBOTS = 500 * 1024
+	// time a robots.txt is kept, and time before asking again for one that
+	// could not be read
+	CRAWLER_ROBOTS_TTL   = 24 * time.Hour
+	CRAWLER_ROBOTS_RETRY = time.Minute
 )
 
+// Kinds of crawl errors.
+const (
+	CRAWL_INVALID_URL  = "invalid_url"
+	CRAWL_ROBOTS       = "robots"
+	CRAWL_FETCH        = "fetch"
+	CRAWL_TIMEOUT      = "timeout"
+	CRAWL_REDIRECTS    = "redirects"
+	CRAWL_STATUS       = "status"
+	CRAWL_TOO_LARGE    = "too_large"
+	CRAWL_CONTENT_TYPE = "content_type"
+	CRAWL_EXTRACT      = "extract"
+)
+
+var errTooManyRedirects = errors.New("too many redirects")
+
+// CrawlError is the reason a page could not be crawled.
+type CrawlError struct {
+	Kind string
+	URL  string
+	// HTTP status of the response, if any
+	Status int
+	Err    error
+}
+
+func (this *CrawlError) Error() string {
+	message := this.Kind + " error crawling " + this.URL
+	if this.Status != 0 {
+		message += fmt.Sprintf(" (HTTP %d)", this.Status)
+	}
+	if this.Err != nil {
+		message += ": " + this.Err.Error()
+	}
+	return message
+}
+
+func (this *CrawlError) ToEntity() *models.ErrorEntity {
+	entity := &models.ErrorEntity{
+		Kind:    this.Kind,
+		Url:     this.URL,
+		Status:  this.Status,
+		Message: this.Error(),
+	}
+	return entity
+}
+
+// CrawlErrorEntity converts an error crawling a page, a fetch error unless
+// it is a *CrawlError.
+func CrawlErrorEntity(pageURL string, err error) *models.ErrorEntity {
+	if crawlError, ok := err.(*CrawlError); ok {
+		return crawlError.ToEntity()
+	}
+	return &models.ErrorEntity{Kind: CRAWL_FETCH, Url: pageURL, Message: err.Error()}
+}
+
 type Crawler struct {
-	name    string
-	timeout int64
-	loop    bool
-	npages  int
-	nlevels int
+	name         string
+	userAgent    string
+	timeout      int64
+	maxRedirects int
+	maxSize      int64
+	robots       bool
+	npages       int
+	nlevels      int
+	delay        int64
+	client       *http.Client
+	robotsCache  map[string]*robotsRules
+	robotsMutex  sync.Mutex
 }
 
 func NewDefaultCrawler() *Crawler {
-	return &Crawler{
-		name:    "default",
-		timeout: 3000,
-		loop:    false,
-		npages:  0,
-		nlevels: 0,
+	this := &Crawler{
+		name:         "default",
+		userAgent:    CRAWLER_USER_AGENT,
+		timeout:      CRAWLER_TIMEOUT,
+		maxRedirects: CRAWLER_MAX_REDIRECTS,
+		maxSize:      CRAWLER_MAX_SIZE,
+		robots:       true,
+		npages:       CRAWLER_PAGES,
+		nlevels:      CRAWLER_LEVELS,
+		delay:        CRAWLER_DELAY,
+		robotsCache:  make(map[string]*robotsRules),
+	}
+	this.client = &http.Client{CheckRedirect: this.checkRedirect}
+	return this
+}
+
+// SetTimeout sets the time allowed to fetch a page, in milliseconds.
+func (this *Crawler) SetTimeout(timeout int64) { this.timeout = timeout }
+func (this *Crawler) GetTimeout() int64        { return this.timeout }
+
+func (this *Crawler) SetUserAgent(userAgent string) { this.userAgent = userAgent }
+func (this *Crawler) GetUserAgent() string          { return this.userAgent }
+
+func (this *Crawler) SetMaxRedirects(n int) { this.maxRedirects = n }
+func (this *Crawler) GetMaxRedirects() int  { return this.maxRedirects }
+
+// SetMaxSize sets the largest page fetched, in bytes.
+func (this *Crawler) SetMaxSize(size int64) { this.maxSize = size }
+func (this *Crawler) GetMaxSize() int64     { return this.maxSize }
+
+// SetRobots sets whether robots.txt rules are obeyed.
+func (this *Crawler) SetRobots(robots bool) { this.robots = robots }
+func (this *Crawler) GetRobots() bool       { return this.robots }
+
+// SetPages sets the largest number of pages fetched by a crawl job.
+func (this *Crawler) SetPages(npages int) { this.npages = npages }
+func (this *Crawler) GetPages() int       { return this.npages }
+
+// SetLevels sets how many links a crawl job follows from its seed page.
+func (this *Crawler) SetLevels(nlevels int) { this.nlevels = nlevels }
+func (this *Crawler) GetLevels() int        { return this.nlevels }
+
+// SetDelay sets the time waited between two requests of a crawl job, in
+// milliseconds. A longer Crawl-delay in robots.txt is obeyed.
+func (this *Crawler) SetDelay(delay int64) { this.delay = delay }
+func (this *Crawler) GetDelay() int64      { return this.delay }
+
+func (this *Crawler) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > this.maxRedirects {
+		return errTooManyRedirects
+	}
+	req.Header.Set("User-Agent", this.userAgent)
+	return nil
+}
+
+// Analyze fetches a page and extracts its article. Failures are returned as
+// a *CrawlError.
+func (this *Crawler) Analyze(pageURL string) (*goose.Article, error) {
+	page, finalURL, err := this.Fetch(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	return this.extract(pageURL, page, finalURL)
+}
+
+// extract extracts the article of a page fetched from pageURL, finalURL
+// after redirects.
+func (this *Crawler) extract(pageURL string, page string, finalURL string) (article *goose.Article, err error) {
+	// the extractor panics on some malformed pages
+	defer func() {
+		if r := recover(); r != nil {
+			article = nil
+			err = &CrawlError{Kind: CRAWL_EXTRACT, URL: pageURL, Err: fmt.Errorf("%v", r)}
+		}
+	}()
+	article, err = goose.New().ExtractFromRawHTML(page, finalURL)
+	if err != nil {
+		return nil, &CrawlError{Kind: CRAWL_EXTRACT, URL: pageURL, Err: err}
+	}
+	return article, nil
+}
+
+// Fetch downloads an HTML page, decoded to UTF-8, and returns it with its
+// URL after redirects. Failures are returned as a *CrawlError.
+func (this *Crawler) Fetch(pageURL string) (string, string, error) {
+	return this.FetchContext(context.Background(), pageURL)
+}
+
+// FetchContext is Fetch, giving up when ctx is done.
+func (this *Crawler) FetchContext(ctx context.Context, pageURL string) (string, string, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		if err == nil {
+			err = errors.New("only absolute http and https URLs can be crawled")
+		}
+		return "", "", &CrawlError{Kind: CRAWL_INVALID_URL, URL: pageURL, Err: err}
+	}
+	if this.robots && !this.allowed(u) {
+		return "", "", &CrawlError{Kind: CRAWL_ROBOTS, URL: pageURL, Err: errors.New("disallowed by robots.txt")}
+	}
+
+	res, err := this.get(ctx, u.String(), "text/html,application/xhtml+xml;q=0.9,*/*;q=0.5")
+	if err != nil {
+		return "", "", this.fetchError(pageURL, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", "", &CrawlError{Kind: CRAWL_STATUS, URL: pageURL, Status: res.StatusCode}
+	}
+	contentType := res.Header.Get("Content-Type")
+	if contentType != "" && !htmlContentType(contentType) {
+		return "", "", &CrawlError{Kind: CRAWL_CONTENT_TYPE, URL: pageURL, Status: res.StatusCode, Err: errors.New("not an HTML page: " + contentType)}
+	}
+	if this.maxSize > 0 && res.ContentLength > this.maxSize {
+		return "", "", &CrawlError{Kind: CRAWL_TOO_LARGE, URL: pageURL, Status: res.StatusCode, Err: fmt.Errorf("%d bytes", res.ContentLength)}
+	}
+
+	content, err := readLimited(res.Body, this.maxSize)
+	if err == errTooLarge {
+		return "", "", &CrawlError{Kind: CRAWL_TOO_LARGE, URL: pageURL, Status: res.StatusCode, Err: fmt.Errorf("more than %d bytes", this.maxSize)}
+	} else if err != nil {
+		return "", "", this.fetchError(pageURL, err)
+	}
+
+	encoding, name, _ := charset.DetermineEncoding(content, contentType)
+	if decoded, err := encoding.NewDecoder().Bytes(content); err == nil {
+		content = decoded
+	} else {
+		LOG.Warn("Could not decode " + pageURL + " from " + name)
+	}
+	return string(content), res.Request.URL.String(), nil
+}
+
+func (this *Crawler) get(ctx context.Context, u string, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", this.userAgent)
+	req.Header.Set("Accept", accept)
+	client := *this.client
+	client.Timeout = time.Duration(this.timeout) * time.Millisecond
+	return client.Do(req)
+}
+
+func (this *Crawler) fetchError(pageURL string, err error) *CrawlError {
+	if e, ok := err.(*url.Error); ok {
+		if e.Err == errTooManyRedirects {
+			return &CrawlError{Kind: CRAWL_REDIRECTS, URL: pageURL, Err: e.Err}
+		}
+		if e.Timeout() {
+			return &CrawlError{Kind: CRAWL_TIMEOUT, URL: pageURL, Err: e.Err}
+		}
+	}
+	if e, ok := err.(interface {
+		Timeout() bool
+	}); ok && e.Timeout() {
+		return &CrawlError{Kind: CRAWL_TIMEOUT, URL: pageURL, Err: err}
+	}
+	return &CrawlError{Kind: CRAWL_FETCH, URL: pageURL, Err: err}
+}
+
+func htmlContentType(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml" || mediaType == "text/plain"
+}
+
+var errTooLarge = errors.New("too large")
+
+// readLimited reads r up to max bytes, or all of it when max is 0.
+func readLimited(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		return ioutil.ReadAll(r)
+	}
+	content, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > max {
+		return nil, errTooLarge
+	}
+	return content, nil
+}
+
+type robotsRule struct {
+	path  string
+	allow bool
+}
+
+// robotsRules are the rules of a robots.txt that apply to the crawler.
+type robotsRules struct {
+	rules []robotsRule
+	// Crawl-delay, in seconds
+	delay float64
+	// nothing can be crawled, as the robots.txt is unreachable
+	disallowed bool
+	expires    time.Time
+}
+
+// allowed tells whether robots.txt of the site allows crawling a URL. As RFC
+// 9309 says, sites without a robots.txt (a 4xx answer) are crawled, and
+// sites whose robots.txt is unreachable (a 5xx answer or a network error)
+// are not.
+func (this *Crawler) allowed(u *url.URL) bool {
+	rules := this.robotsOf(u)
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
 	}
+	return rules.allows(path)
 }
 
-func (this *Crawler) Analyze(url string) *goose.Article {
-	g := goose.New()
-	article, err := g.ExtractFromURL(url)
+// robotsOf returns the robots.txt rules of the site of a URL, read again
+// once a day, or after a minute when it was unreachable.
+func (this *Crawler) robotsOf(u *url.URL) *robotsRules {
+	site := u.Scheme + "://" + u.Host
+	this.robotsMutex.Lock()
+	rules, ok := this.robotsCache[site]
+	this.robotsMutex.Unlock()
+
+	if !ok || time.Now().After(rules.expires) {
+		rules = this.fetchRobots(site)
+		this.robotsMutex.Lock()
+		this.robotsCache[site] = rules
+		this.robotsMutex.Unlock()
+	}
+	return rules
+}
+
+func (this *Crawler) fetchRobots(site string) *robotsRules {
+	unreachable := &robotsRules{disallowed: true, expires: time.Now().Add(CRAWLER_ROBOTS_RETRY)}
+	// the rules are cached for every job, so a cancelled one does not
+	// leave the site unreachable
+	res, err := this.get(context.Background(), site+"/robots.txt", "text/plain")
 	if err != nil {
-		// TODO Probably want to handle this error...
-		panic(err)
+		LOG.Warn("Could not read " + site + "/robots.txt: " + err.Error())
+		return unreachable
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusInternalServerError {
+		LOG.Warn("Could not read " + site + "/robots.txt: " + res.Status)
+		return unreachable
+	}
+
+	rules := new(robotsRules)
+	if res.StatusCode == http.StatusOK {
+		content, err := ioutil.ReadAll(io.LimitReader(res.Body, CRAWLER_MAX_ROBOTS))
+		if err != nil {
+			LOG.Warn("Could not read " + site + "/robots.txt: " + err.Error())
+			return unreachable
+		}
+		rules = parseRobots(content, this.userAgent)
+	}
+	rules.expires = time.Now().Add(CRAWLER_ROBOTS_TTL)
+	return rules
+}
+
+// parseRobots reads the rules of the group of a robots.txt that names the
+// product token of the user agent (before the /, ignoring case, as RFC 9309
+// says), or of the * group when none does.
+func parseRobots(content []byte, userAgent string) *robotsRules {
+	agent := strings.ToLower(strings.TrimSpace(strings.Split(userAgent, "/")[0]))
+	own := new(robotsRules)
+	any := new(robotsRules)
+	foundOwn := false
+
+	// agents of the current group, and whether its rules started
+	agents := make([]string, 0)
+	inRules := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents = agents[:0]
+				inRules = false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "crawl-delay":
+			inRules = true
+			delay, err := strconv.ParseFloat(value, 64)
+			if err != nil || delay < 0 {
+				continue
+			}
+			for _, a := range agents {
+				if a == "*" {
+					any.delay = delay
+				} else if agent != "" && a == agent {
+					own.delay = delay
+					foundOwn = true
+				}
+			}
+		case "allow", "disallow":
+			inRules = true
+			// an empty rule allows everything, but still makes the group
+			// the one of the crawler
+			rule := robotsRule{path: value, allow: key == "allow"}
+			for _, a := range agents {
+				if a == "*" {
+					if value != "" {
+						any.rules = append(any.rules, rule)
+					}
+				} else if agent != "" && a == agent {
+					if value != "" {
+						own.rules = append(own.rules, rule)
+					}
+					foundOwn = true
+				}
+			}
+		}
+	}
+	if foundOwn {
+		return own
+	}
+	return any
+}
+
+// allows applies the longest matching rule, allow winning ties.
+func (this *robotsRules) allows(path string) bool {
+	if this.disallowed {
+		return false
+	}
+	allowed := true
+	longest := -1
+	for _, rule := range this.rules {
+		if !robotsMatch(rule.path, path) {
+			continue
+		}
+		if len(rule.path) > longest || (len(rule.path) == longest && rule.allow) {
+			longest = len(rule.path)
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// robotsMatch matches a path against a robots.txt path prefix, which may
+// hold * wildcards and end with $.
+func robotsMatch(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for _, part := range parts[1:] {
+		i := strings.Index(path[pos:], part)
+		if i < 0 {
+			return false
+		}
+		pos += i + len(part)
+	}
+	if !anchored {
+		return true
+	}
+	if len(parts) == 1 {
+		return pos == len(path)
 	}
-	return article
+	return strings.HasSuffix(path[len(parts[0]):], parts[len(parts)-1])
 }
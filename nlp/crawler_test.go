@@ -0,0 +1,345 @@
+package nlp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSite serves the pages used by the crawler tests.
+func newTestSite(robots func(w http.ResponseWriter)) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		if robots == nil {
+			http.NotFound(w, r)
+			return
+		}
+		robots(w)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body><p>The cat sits.</p></body></html>")
+	})
+	mux.HandleFunc("/private/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body><p>Private.</p></body></html>")
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		fmt.Fprint(w, "<html></html>")
+	})
+	mux.HandleFunc("/redirect/", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/redirect/"))
+		http.Redirect(w, r, fmt.Sprintf("/redirect/%d", n+1), http.StatusFound)
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Length", "2048")
+		w.Write([]byte(strings.Repeat("a", 2048)))
+	})
+	mux.HandleFunc("/chunked", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		// flushing before the end leaves the length unknown
+		w.Write([]byte(strings.Repeat("a", 512)))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(strings.Repeat("a", 1536)))
+	})
+	mux.HandleFunc("/document.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		fmt.Fprint(w, "%PDF-1.4")
+	})
+	mux.HandleFunc("/latin1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Write([]byte("<html><body><p>Caf\xe9 con le\xf1a.</p></body></html>"))
+	})
+	return httptest.NewServer(mux)
+}
+
+func newTestCrawler() *Crawler {
+	crawler := NewDefaultCrawler()
+	crawler.SetTimeout(200)
+	crawler.SetMaxRedirects(3)
+	crawler.SetMaxSize(1024)
+	crawler.SetRobots(false)
+	return crawler
+}
+
+// crawlErrorKind returns the kind of the error of a Fetch, or "" if it did
+// not fail.
+func crawlErrorKind(t *testing.T, err error) string {
+	if err == nil {
+		return ""
+	}
+	crawlError, ok := err.(*CrawlError)
+	if !ok {
+		t.Fatalf("%v is not a *CrawlError", err)
+	}
+	return crawlError.Kind
+}
+
+func TestFetchErrors(t *testing.T) {
+	site := newTestSite(nil)
+	defer site.Close()
+	crawler := newTestCrawler()
+
+	tests := []struct {
+		path string
+		kind string
+	}{
+		{"/page", ""},
+		{"/slow", CRAWL_TIMEOUT},
+		{"/redirect/0", CRAWL_REDIRECTS},
+		{"/large", CRAWL_TOO_LARGE},
+		{"/chunked", CRAWL_TOO_LARGE},
+		{"/document.pdf", CRAWL_CONTENT_TYPE},
+		{"/missing", CRAWL_STATUS},
+	}
+	for _, test := range tests {
+		_, _, err := crawler.Fetch(site.URL + test.path)
+		if kind := crawlErrorKind(t, err); kind != test.kind {
+			t.Errorf("%s: got error kind %q, expected %q (%v)", test.path, kind, test.kind, err)
+		}
+	}
+
+	_, _, err := crawler.Fetch(site.URL + "/missing")
+	if status := err.(*CrawlError).Status; status != http.StatusNotFound {
+		t.Errorf("got status %d, expected 404", status)
+	}
+
+	_, _, err = crawler.Fetch("ftp://example.com/")
+	if kind := crawlErrorKind(t, err); kind != CRAWL_INVALID_URL {
+		t.Errorf("got error kind %q, expected %q", kind, CRAWL_INVALID_URL)
+	}
+}
+
+// TestFetchRedirects follows a chain of three redirects, with a limit of
+// three and then two.
+func TestFetchRedirects(t *testing.T) {
+	crawler := newTestCrawler()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if n < 3 {
+			http.Redirect(w, r, fmt.Sprintf("/%d", n+1), http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "<html></html>")
+	})
+	chain := httptest.NewServer(mux)
+	defer chain.Close()
+	_, final, err := crawler.Fetch(chain.URL + "/0")
+	if err != nil {
+		t.Fatalf("three redirects failed: %v", err)
+	}
+	if final != chain.URL+"/3" {
+		t.Errorf("got final URL %s, expected %s/3", final, chain.URL)
+	}
+	crawler.SetMaxRedirects(2)
+	if _, _, err := crawler.Fetch(chain.URL + "/0"); crawlErrorKind(t, err) != CRAWL_REDIRECTS {
+		t.Errorf("three redirects followed with a limit of two: %v", err)
+	}
+}
+
+func TestFetchCharset(t *testing.T) {
+	site := newTestSite(nil)
+	defer site.Close()
+
+	page, _, err := newTestCrawler().Fetch(site.URL + "/latin1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page, "Café con leña.") {
+		t.Errorf("page not decoded from ISO-8859-1: %q", page)
+	}
+}
+
+func TestFetchRobots(t *testing.T) {
+	site := newTestSite(func(w http.ResponseWriter) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+	})
+	defer site.Close()
+	crawler := newTestCrawler()
+	crawler.SetRobots(true)
+
+	if _, _, err := crawler.Fetch(site.URL + "/page"); err != nil {
+		t.Errorf("allowed page not fetched: %v", err)
+	}
+	if _, _, err := crawler.Fetch(site.URL + "/private/page"); crawlErrorKind(t, err) != CRAWL_ROBOTS {
+		t.Errorf("disallowed page fetched: %v", err)
+	}
+
+	crawler.SetRobots(false)
+	if _, _, err := crawler.Fetch(site.URL + "/private/page"); err != nil {
+		t.Errorf("page not fetched ignoring robots.txt: %v", err)
+	}
+}
+
+func TestFetchRobotsUnreachable(t *testing.T) {
+	site := newTestSite(func(w http.ResponseWriter) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	defer site.Close()
+	crawler := newTestCrawler()
+	crawler.SetRobots(true)
+	if _, _, err := crawler.Fetch(site.URL + "/page"); crawlErrorKind(t, err) != CRAWL_ROBOTS {
+		t.Errorf("page fetched with a 5xx robots.txt: %v", err)
+	}
+
+	// a missing robots.txt allows everything
+	missing := newTestSite(nil)
+	defer missing.Close()
+	if _, _, err := crawler.Fetch(missing.URL + "/private/page"); err != nil {
+		t.Errorf("page not fetched without robots.txt: %v", err)
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	robots := `# comment
+User-agent: *
+Disallow: /
+
+User-agent: go-freeling
+Disallow:
+`
+	rules := parseRobots([]byte(robots), CRAWLER_USER_AGENT)
+	if !rules.allows("/page") {
+		t.Error("empty Disallow of the own group does not allow everything")
+	}
+
+	rules = parseRobots([]byte(robots), "other/1.0")
+	if rules.allows("/page") {
+		t.Error("other agents do not follow the * group")
+	}
+
+	// a group of a part of the product token is not the one of the crawler
+	rules = parseRobots([]byte("User-agent: *\nDisallow: /\n\nUser-agent: go\nDisallow:\n"), CRAWLER_USER_AGENT)
+	if rules.allows("/page") {
+		t.Error("the go group taken as the one of go-freeling")
+	}
+
+	robots = `User-agent: Go-Freeling
+User-agent: other
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 2
+`
+	rules = parseRobots([]byte(robots), CRAWLER_USER_AGENT)
+	if rules.delay != 2 {
+		t.Errorf("got Crawl-delay %v, expected 2", rules.delay)
+	}
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{"/", true},
+		{"/private", false},
+		{"/private/page", false},
+		{"/private/public/page", true},
+		{"/files/document.pdf", false},
+		{"/files/document.pdf?download=1", true},
+	}
+	for _, test := range tests {
+		if allowed := rules.allows(test.path); allowed != test.allowed {
+			t.Errorf("%s: allowed %v, expected %v", test.path, allowed, test.allowed)
+		}
+	}
+}
+
+// newTestLinks serves a site of linked pages for the crawl job tests: the
+// seed links to /a, to /b with nofollow, to another host and to /c, and /a
+// links to /dir/d through its <base>.
+func newTestLinks() *httptest.Server {
+	pages := map[string]string{
+		"/": `<a href="/a">a</a> <a rel="nofollow" href="/b">b</a>
+<a href="http://other.example/x">x</a> <a href="/c#top">c</a> <a href="/a">again</a>`,
+		"/a":     `<base href="/dir/"><a href="d">d</a>`,
+		"/b":     `<p>Not followed.</p>`,
+		"/c":     `<p>The cat sits.</p>`,
+		"/dir/d": `<p>The dog sits.</p>`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body>"+page+"</body></html>")
+	})
+	return httptest.NewServer(mux)
+}
+
+// crawledPaths runs a job and returns the path and level of every page it
+// visits.
+func crawledPaths(t *testing.T, job *CrawlJob, site string) []string {
+	paths := make([]string, 0)
+	err := job.Run(context.Background(), func(page *CrawledPage) bool {
+		if page.Err != nil {
+			t.Errorf("%s: %v", page.URL, page.Err)
+		}
+		paths = append(paths, fmt.Sprintf("%s:%d", strings.TrimPrefix(page.URL, site), page.Level))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func TestCrawlJob(t *testing.T) {
+	site := newTestLinks()
+	defer site.Close()
+	crawler := newTestCrawler()
+	crawler.SetDelay(0)
+
+	tests := []struct {
+		pages  int
+		levels int
+		paths  string
+	}{
+		{0, 1, "/:0 /a:1 /c:1"},
+		{0, 0, "/:0 /a:1 /c:1 /dir/d:2"},
+		{2, 0, "/:0 /a:1"},
+	}
+	for _, test := range tests {
+		job := crawler.NewCrawlJob(site.URL + "/")
+		job.SetPages(test.pages)
+		job.SetLevels(test.levels)
+		if paths := strings.Join(crawledPaths(t, job, site.URL), " "); paths != test.paths {
+			t.Errorf("%d pages, %d levels: got %s, expected %s", test.pages, test.levels, paths, test.paths)
+		}
+	}
+}
+
+// TestCrawlJobCancel cancels a job while it waits for the crawler delay.
+func TestCrawlJobCancel(t *testing.T) {
+	site := newTestLinks()
+	defer site.Close()
+	crawler := newTestCrawler()
+	crawler.SetDelay(10000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	visited := 0
+	start := time.Now()
+	err := crawler.NewCrawlJob(site.URL+"/").Run(ctx, func(page *CrawledPage) bool {
+		visited++
+		cancel()
+		return true
+	})
+	if err != context.Canceled {
+		t.Errorf("got %v, expected context.Canceled", err)
+	}
+	if visited != 1 {
+		t.Errorf("visited %d pages after the cancel", visited)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancel took %v", elapsed)
+	}
+}
@@ -2,10 +2,12 @@ package nlp
 
 import (
 	"container/list"
+	"github.com/advancedlogic/go-freeling/models"
 	"github.com/fatih/set"
 	"math"
 	"strconv"
 	"strings"
+	"sync"
 	//"os"
 )
 
@@ -34,10 +36,16 @@ type Dictionary struct {
 
 	lemmaPrefs map[string]string
 	posPrefs   map[string]string
+
+	userFile    string
+	userEntries map[string][]*models.LexiconEntry
+	userMutex   sync.RWMutex
 }
 
 func NewDictionary(Lang string, dicFile string, sufFile string, compFile string, invDic bool, retok bool) *Dictionary {
-	this := Dictionary{}
+	this := Dictionary{
+		userEntries: make(map[string][]*models.LexiconEntry),
+	}
 
 	this.InverseDic = invDic
 	this.RetokenizeContractions = retok
@@ -57,6 +65,14 @@ func NewDictionary(Lang string, dicFile string, sufFile string, compFile string,
 	}
 	this.CompoundAnalysis = (this.comp != nil)
 
+	this.lemmaPrefs = make(map[string]string)
+	this.posPrefs = make(map[string]string)
+
+	if this.readBinary(dicFile) {
+		LOG.Trace("Dictionary loaded from " + BinaryFile(dicFile))
+		return &this
+	}
+
 	cfg := NewConfigFile(false, "##")
 	cfg.AddSection("IndexType", DICTIONARY_INDEX)
 	cfg.AddSection("LemmaPreferences", DICTIONARY_LEMMA_PREF)
@@ -283,6 +299,17 @@ func (this *Dictionary) SearchForm(s string, la *list.List) {
 			p = If(q == -1, -1, p+q+1).(int)
 		}
 	}
+
+	for _, entry := range this.userAnalyses(key) {
+		found := false
+		for a := la.Front(); a != nil && !found; a = a.Next() {
+			found = a.Value.(*Analysis).getLemma() == entry.Lemma && a.Value.(*Analysis).getTag() == entry.Tag
+		}
+		if !found {
+			LOG.Trace("Adding user entry (" + entry.Lemma + "," + entry.Tag + ") to analysis list")
+			la.PushBack(NewAnalysis(entry.Lemma, entry.Tag))
+		}
+	}
 }
 
 func (this *Dictionary) tagCombination(p *list.Element, last *list.Element) *list.List {
@@ -505,3 +532,50 @@ func (this *Dictionary) Analyze(se *Sentence) {
 		se.rebuildWordIndex()
 	}
 }
+
+// dictionaryData is the compiled form of a dictionary.
+type dictionaryData struct {
+	Entries    map[string]string
+	Inverse    map[string]string
+	LemmaPrefs map[string]string
+	PosPrefs   map[string]string
+}
+
+func (this *Dictionary) readBinary(dicFile string) bool {
+	var data dictionaryData
+	if !readBinary(dicFile, BINARY_DICTIONARY, &data) {
+		return false
+	}
+	// compiled without the inverse dictionary
+	if this.InverseDic && data.Inverse == nil {
+		return false
+	}
+
+	this.morfodb = &Database{DBType: DB_MAP, dbmap: data.Entries}
+	if data.Inverse != nil {
+		this.inverdb = &Database{DBType: DB_MAP, dbmap: data.Inverse}
+	}
+	if data.LemmaPrefs != nil {
+		this.lemmaPrefs = data.LemmaPrefs
+	}
+	if data.PosPrefs != nil {
+		this.posPrefs = data.PosPrefs
+	}
+	return true
+}
+
+// Compile writes the compiled version of the dictionary loaded from
+// dicFile. User entries are not included.
+func (this *Dictionary) Compile(dicFile string) error {
+	data := dictionaryData{
+		LemmaPrefs: this.lemmaPrefs,
+		PosPrefs:   this.posPrefs,
+	}
+	if this.morfodb != nil {
+		data.Entries = this.morfodb.dbmap
+	}
+	if this.inverdb != nil {
+		data.Inverse = this.inverdb.dbmap
+	}
+	return writeBinary(dicFile, BINARY_DICTIONARY, &data)
+}
@@ -2,10 +2,14 @@ package nlp
 
 import (
 	"container/list"
-	"github.com/fatih/set"
 	"io/ioutil"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/fatih/set"
+
+	"github.com/advancedlogic/go-freeling/models"
 )
 
 const (
@@ -104,20 +108,140 @@ func NewDisambiguator(disFile string) *Disambiguator {
 	return &this
 }
 
+func (this *Synset) toEntity() *models.SynsetEntity {
+	return &models.SynsetEntity{
+		Wnid:     this.wnid,
+		Domain:   this.domain,
+		Positive: this.pos,
+		Negative: this.neg,
+		Score:    this.score,
+		Gloss:    this.gloss,
+	}
+}
+
+// candidates returns the synsets of the knowledge base matching the senses
+// of the selected analysis of w, best ranked first.
+func (this *Disambiguator) candidates(w *Word) []*Synset {
+	if w.getNAnalysis() == 0 || w.selectedBegin(0).Element == nil {
+		return nil
+	}
+
+	senses := List2FloatPairsArray(w.getSenses(0))
+	sort.SliceStable(senses, func(i, j int) bool { return senses[i].second > senses[j].second })
+
+	out := make([]*Synset, 0)
+	for _, pair := range senses {
+		if syn := this.wnids[pair.first]; syn != nil {
+			out = append(out, syn)
+		}
+	}
+	return out
+}
+
+// bound tells whether two domains support each other: they are equal or
+// related by a SENTENCE_BIND entry.
+func (this *Disambiguator) bound(d1 string, d2 string) bool {
+	if d1 == d2 {
+		return true
+	}
+	if b := this.binds[d1]; b != nil && b.Has(d2) {
+		return true
+	}
+	if b := this.binds[d2]; b != nil && b.Has(d1) {
+		return true
+	}
+	return false
+}
+
+func (this *Disambiguator) supported(syn *Synset, domains []string) bool {
+	for _, d := range domains {
+		if this.bound(syn.domain, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// Analyze attaches to every word the best ranked synset of the knowledge base
+// whose scope is satisfied. DOCUMENT_SCOPE synsets are always accepted,
+// SENTENCE_SCOPE ones need another word of the same sentence with a candidate
+// in the same (or a bound) domain, and ND_SCOPE ones need an accepted synset
+// in the same (or a bound) domain anywhere in the document. A word whose best
+// remaining candidate is ND_SCOPE waits for the whole document to be seen
+// before its lower ranked candidates are considered.
 func (this *Disambiguator) Analyze(ss *list.List) {
+	pending := make([]*pendingWord, 0)
+	docDomains := make([]string, 0)
+
 	for s := ss.Front(); s != nil; s = s.Next() {
+		words := make([]*Word, 0)
+		cands := make([][]*Synset, 0)
 		for w := s.Value.(*Sentence).Front(); w != nil; w = w.Next() {
-			lsens := w.Value.(*Word).getSenses(0)
-			for l := lsens.Front(); l != nil; l = l.Next() {
-				pair := l.Value.(FloatPair)
-				id := pair.first
-				prob := pair.second
-				if prob > 0 {
-					if this.wnids[id] != nil {
-						println(id, w.Value.(*Word).getForm(), this.wnids[id].lemma, this.wnids[id].gloss, this.wnids[id].domain)
-					}
+			word := w.Value.(*Word)
+			word.setSynset(nil)
+			if c := this.candidates(word); len(c) > 0 {
+				words = append(words, word)
+				cands = append(cands, c)
+			}
+		}
+
+		for i, word := range words {
+			others := make([]string, 0)
+			for j, c := range cands {
+				if j == i {
+					continue
+				}
+				for _, syn := range c {
+					others = append(others, syn.domain)
+				}
+			}
+
+			for k, syn := range cands[i] {
+				if syn.scope == ND_SCOPE {
+					pending = append(pending, &pendingWord{word, cands[i][k:], others})
+					break
+				}
+				if this.accepts(syn, others, nil) {
+					word.setSynset(syn)
+					docDomains = append(docDomains, syn.domain)
+					LOG.Trace("Synset " + syn.wnid + " selected for " + word.getForm())
+					break
 				}
 			}
 		}
 	}
+
+	for _, p := range pending {
+		for _, syn := range p.cands {
+			if this.accepts(syn, p.others, docDomains) {
+				p.word.setSynset(syn)
+				LOG.Trace("Synset " + syn.wnid + " selected for " + p.word.getForm())
+				break
+			}
+		}
+	}
+}
+
+// pendingWord is a word whose best remaining candidate is ND_SCOPE, with the
+// candidates from that one on and the domains of the other words of its
+// sentence.
+type pendingWord struct {
+	word   *Word
+	cands  []*Synset
+	others []string
+}
+
+// accepts tells whether the scope of a synset is satisfied, given the
+// candidate domains of the other words of the sentence and the domains
+// accepted in the document (nil while the document is being read).
+func (this *Disambiguator) accepts(syn *Synset, others []string, docDomains []string) bool {
+	switch syn.scope {
+	case DOCUMENT_SCOPE:
+		return true
+	case SENTENCE_SCOPE:
+		return this.supported(syn, others)
+	case ND_SCOPE:
+		return this.supported(syn, docDomains)
+	}
+	return false
 }
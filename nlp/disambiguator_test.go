@@ -0,0 +1,53 @@
+package nlp
+
+import (
+	"container/list"
+	"testing"
+)
+
+// senseWord returns a word whose selected analysis has the given senses,
+// best ranked first.
+func senseWord(form string, senses ...string) *Word {
+	w := NewWordFromLemma(form)
+	w.addAnalysis(NewAnalysis(form, "NN"))
+	ranked := list.New()
+	for i, sense := range senses {
+		ranked.PushBack(FloatPair{sense, float64(len(senses) - i)})
+	}
+	w.setSenses(ranked, 0)
+	return w
+}
+
+func sentenceOf(words ...*Word) *Sentence {
+	s := NewSentence()
+	for _, w := range words {
+		s.PushBack(w)
+	}
+	return s
+}
+
+// TestDisambiguatorPending checks that a best ranked ND_SCOPE synset is
+// chosen over a lower ranked DOCUMENT_SCOPE one when the document supports
+// its domain, and that the lower ranked one is taken otherwise.
+func TestDisambiguatorPending(t *testing.T) {
+	disambiguator := NewDisambiguator("testdata/knowledge.dat")
+
+	bank := senseWord("bank", "08420278-n", "09213565-n")
+	money := senseWord("money", "13384557-n")
+	sentences := list.New()
+	sentences.PushBack(sentenceOf(bank))
+	sentences.PushBack(sentenceOf(money))
+	disambiguator.Analyze(sentences)
+	if syn := bank.getSynset(); syn == nil || syn.wnid != "08420278-n" {
+		t.Errorf("bank near money: got %v, expected the economy synset", syn)
+	}
+
+	bank = senseWord("bank", "08420278-n", "09213565-n")
+	river := senseWord("river", "09411430-n")
+	sentences = list.New()
+	sentences.PushBack(sentenceOf(bank, river))
+	disambiguator.Analyze(sentences)
+	if syn := bank.getSynset(); syn == nil || syn.wnid != "09213565-n" {
+		t.Errorf("bank near river: got %v, expected the geography synset", syn)
+	}
+}
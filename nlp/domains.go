@@ -0,0 +1,156 @@
+package nlp
+
+import (
+	"container/list"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/advancedlogic/go-freeling/models"
+)
+
+const DOMAIN_FACTOTUM = "factotum"
+
+// WordNet lexicographer file names, indexed by their number in lexnames.
+var lexNames = []string{
+	"adj.all", "adj.pert", "adv.all", "noun.Tops", "noun.act", "noun.animal", "noun.artifact",
+	"noun.attribute", "noun.body", "noun.cognition", "noun.communication", "noun.event",
+	"noun.feeling", "noun.food", "noun.group", "noun.location", "noun.motive", "noun.object",
+	"noun.person", "noun.phenomenon", "noun.plant", "noun.possession", "noun.process",
+	"noun.quantity", "noun.relation", "noun.shape", "noun.state", "noun.substance", "noun.time",
+	"verb.body", "verb.change", "verb.cognition", "verb.communication", "verb.competition",
+	"verb.consumption", "verb.contact", "verb.creation", "verb.emotion", "verb.motion",
+	"verb.perception", "verb.possession", "verb.social", "verb.stative", "verb.weather", "adj.ppl",
+}
+
+func lexName(semFile string) string {
+	n, err := strconv.Atoi(semFile)
+	if err != nil || n < 0 || n >= len(lexNames) {
+		return semFile
+	}
+	return lexNames[n]
+}
+
+// Domains builds a topical profile of a document out of the WordNet Domains
+// of the synsets chosen by the Disambiguator and the lexicographer files of
+// the best ranked sense of every noun and verb.
+type Domains struct {
+	semdb *SemanticDB
+}
+
+func NewDomains(semdb *SemanticDB) *Domains {
+	return &Domains{
+		semdb: semdb,
+	}
+}
+
+// bestSense returns the best ranked sense of the selected analysis of w and
+// its weight: the UKB rank when senses were ranked, 1 otherwise.
+func (this *Domains) bestSense(w *Word) (string, float64) {
+	if w.getNAnalysis() == 0 || w.selectedBegin(0).Element == nil {
+		return "", 0
+	}
+	best := ""
+	weight := -1.0
+	for s := w.getSenses(0).Front(); s != nil; s = s.Next() {
+		pair := s.Value.(FloatPair)
+		if pair.second > weight {
+			best = pair.first
+			weight = pair.second
+		}
+	}
+	if weight <= 0 {
+		weight = 1
+	}
+	return best, weight
+}
+
+// senseWeight returns the weight of a sense of the selected analysis of w,
+// on the same scale as bestSense: its UKB rank when senses were ranked, 1
+// otherwise.
+func (this *Domains) senseWeight(w *Word, sense string) float64 {
+	if w.getNAnalysis() == 0 || w.selectedBegin(0).Element == nil {
+		return 1
+	}
+	for s := w.getSenses(0).Front(); s != nil; s = s.Next() {
+		pair := s.Value.(FloatPair)
+		if pair.first == sense && pair.second > 0 {
+			return pair.second
+		}
+	}
+	return 1
+}
+
+// Profile adds up the domains of the nouns and verbs of the sentences, both
+// synset domains and lexicographer files weighted by the rank of their sense,
+// and returns them normalized, the heaviest first.
+func (this *Domains) Profile(ss *list.List, filter []string) []*models.DomainEntity {
+	weights := make(map[string]float64)
+	counts := make(map[string]int)
+	total := 0.0
+
+	add := func(name string, weight float64) {
+		if name == "" || name == DOMAIN_FACTOTUM || !this.accepted(name, filter) {
+			return
+		}
+		weights[name] += weight
+		counts[name]++
+		total += weight
+	}
+
+	for s := ss.Front(); s != nil; s = s.Next() {
+		for w := s.Value.(*Sentence).Front(); w != nil; w = w.Next() {
+			word := w.Value.(*Word)
+			tag := word.getTag(0)
+			if !strings.HasPrefix(tag, "N") && !strings.HasPrefix(tag, "V") {
+				continue
+			}
+
+			if syn := word.getSynset(); syn != nil {
+				weight := this.senseWeight(word, syn.wnid)
+				for _, d := range Split(syn.domain, " ") {
+					add(d, weight)
+				}
+			}
+
+			if this.semdb != nil {
+				sense, weight := this.bestSense(word)
+				if sense != "" {
+					add(lexName(this.semdb.getSenseInfo(sense).semFile), weight)
+				}
+			}
+		}
+	}
+
+	profile := make([]*models.DomainEntity, 0)
+	for name, weight := range weights {
+		profile = append(profile, &models.DomainEntity{
+			Name:   name,
+			Weight: weight / total,
+			Count:  counts[name],
+		})
+	}
+
+	sort.Slice(profile, func(i, j int) bool {
+		if profile[i].Weight == profile[j].Weight {
+			return profile[i].Name < profile[j].Name
+		}
+		return profile[i].Weight > profile[j].Weight
+	})
+
+	return profile
+}
+
+// accepted tells whether a domain passes the request filter. Filter entries
+// ending with a dot select a whole family (e.g. "noun.").
+func (this *Domains) accepted(name string, filter []string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, f := range filter {
+		if name == f || (strings.HasSuffix(f, ".") && strings.HasPrefix(name, f)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package nlp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	CORPUS_FREELING = "freeling"
+	CORPUS_CONLL    = "conll"
+)
+
+// HMMTrainer estimates the parameters of an HMMTagger out of a tagged
+// corpus and writes them in the tagger.dat format read by NewHMMTagger.
+// Tags are reduced to their short version with the language tagset, and the
+// linear interpolation coefficients are estimated by deleted interpolation.
+type HMMTrainer struct {
+	Tags      *TagSet
+	tags      map[string]int
+	bigrams   map[string]int
+	trigrams  map[string]int
+	histories map[string]int
+	initial   map[string]int
+	words     map[string]int
+	ntags     int
+	sentences int
+}
+
+func NewHMMTrainer(tags *TagSet) *HMMTrainer {
+	return &HMMTrainer{
+		Tags:      tags,
+		tags:      make(map[string]int),
+		bigrams:   make(map[string]int),
+		trigrams:  make(map[string]int),
+		histories: make(map[string]int),
+		initial:   make(map[string]int),
+		words:     make(map[string]int),
+	}
+}
+
+// AddSentence counts the forms and full tags of a tagged sentence.
+func (this *HMMTrainer) AddSentence(forms []string, tags []string) {
+	if len(forms) == 0 || len(forms) != len(tags) {
+		return
+	}
+	this.sentences++
+
+	short := make([]string, len(tags))
+	for i, tag := range tags {
+		short[i] = this.Tags.GetShortTag(tag)
+		this.tags[short[i]]++
+		this.words[strings.Replace(strings.ToLower(forms[i]), " ", "_", -1)]++
+		this.ntags++
+	}
+
+	this.initial[short[0]]++
+	prev2, prev1 := "0", "0"
+	for i, t := range short {
+		if i > 0 {
+			this.bigrams[prev1+"."+t]++
+		}
+		this.histories[prev2+"."+prev1]++
+		this.trigrams[prev2+"."+prev1+"."+t]++
+		prev2, prev1 = prev1, t
+	}
+}
+
+// ReadCorpus reads sentences separated by blank lines, either in FreeLing
+// format (form lemma tag per line) or in CoNLL format (tab separated, with
+// the form in the second column and the tag in the fifth one).
+func (this *HMMTrainer) ReadCorpus(r io.Reader, format string) error {
+	return readCorpus(r, format, this.AddSentence)
+}
+
+// Smoothing estimates the unigram, bigram and trigram interpolation
+// coefficients by deleted interpolation: every trigram votes, with its
+// frequency, for the order that best predicts it when that occurrence is
+// removed from the counts.
+func (this *HMMTrainer) Smoothing() [3]float64 {
+	var lambda [3]float64
+	for trigram, f := range this.trigrams {
+		t := strings.Split(trigram, ".")
+		var p [3]float64
+		if this.ntags > 1 {
+			p[0] = float64(this.tags[t[2]]-1) / float64(this.ntags-1)
+		}
+		if h := this.tags[t[1]]; t[1] != "0" && h > 1 {
+			p[1] = float64(this.bigrams[t[1]+"."+t[2]]-1) / float64(h-1)
+		}
+		if h := this.histories[t[0]+"."+t[1]]; h > 1 {
+			p[2] = float64(f-1) / float64(h-1)
+		}
+
+		best := 0
+		for i := 1; i < 3; i++ {
+			if p[i] > p[best] {
+				best = i
+			}
+		}
+		lambda[best] += float64(f)
+	}
+
+	total := lambda[0] + lambda[1] + lambda[2]
+	if total == 0 {
+		return [3]float64{1, 0, 0}
+	}
+	for i := range lambda {
+		lambda[i] /= total
+	}
+	return lambda
+}
+
+// Write writes the model in tagger.dat format. tagsetFile is written in the
+// TagsetFile section, relative to the directory of the tagger file.
+func (this *HMMTrainer) Write(out io.Writer, tagsetFile string) error {
+	w := bufio.NewWriter(out)
+	format := func(p float64) string {
+		return strconv.FormatFloat(p, 'g', -1, 64)
+	}
+	section := func(name string, keys []string, value func(string) float64) {
+		w.WriteString("<" + name + ">\n")
+		for _, k := range keys {
+			w.WriteString(k + " " + format(value(k)) + "\n")
+		}
+		w.WriteString("</" + name + ">\n")
+	}
+
+	w.WriteString("## HMM tagger model trained on " + strconv.Itoa(this.sentences) + " sentences, " + strconv.Itoa(this.ntags) + " words\n")
+	w.WriteString("<TagsetFile>\n" + tagsetFile + "\n</TagsetFile>\n")
+
+	// unigrams with add-one smoothing, x stands for unseen tags
+	ntags := float64(this.ntags + len(this.tags) + 1)
+	section("Tag", append(sortedKeys(this.tags), "x"), func(k string) float64 {
+		return float64(this.tags[k]+1) / ntags
+	})
+
+	section("Bigram", sortedKeys(this.bigrams), func(k string) float64 {
+		t := strings.Split(k, ".")
+		return float64(this.bigrams[k]) / float64(this.tags[t[0]])
+	})
+
+	section("Trigram", sortedKeys(this.trigrams), func(k string) float64 {
+		t := strings.Split(k, ".")
+		return float64(this.trigrams[k]) / float64(this.histories[t[0]+"."+t[1]])
+	})
+
+	initial := make(map[string]int)
+	for t, c := range this.initial {
+		initial["0."+t] = c
+	}
+	ninitial := float64(this.sentences + len(this.tags) + 1)
+	section("Initial", append(sortedKeys(initial), UNOBS_INITIAL_STATE), func(k string) float64 {
+		return math.Log(float64(initial[k]+1) / ninitial)
+	})
+
+	nwords := float64(this.ntags + len(this.words) + 1)
+	section("Word", append(sortedKeys(this.words), UNOBS_WORD), func(k string) float64 {
+		return math.Log(float64(this.words[k]+1) / nwords)
+	})
+
+	c := this.Smoothing()
+	w.WriteString("<Smoothing>\n")
+	for i := range c {
+		w.WriteString("c" + strconv.Itoa(i+1) + " " + format(c[i]) + "\n")
+	}
+	w.WriteString("</Smoothing>\n")
+
+	return w.Flush()
+}
+
+// readCorpus calls add with the forms and tags of every sentence of a
+// tagged corpus in the given format.
+func readCorpus(r io.Reader, format string, add func(forms []string, tags []string)) error {
+	forms := make([]string, 0)
+	tags := make([]string, 0)
+	flush := func() {
+		add(forms, tags)
+		forms = forms[:0]
+		tags = tags[:0]
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			flush()
+			continue
+		}
+
+		if format == CORPUS_CONLL {
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			items := strings.Split(line, "\t")
+			if len(items) < 5 {
+				return fmt.Errorf("line %d: expected at least 5 tab separated columns", n)
+			}
+			// skip multiword ranges and empty nodes
+			if strings.ContainsAny(items[0], "-.") {
+				continue
+			}
+			tag := items[4]
+			if tag == "_" {
+				tag = items[3]
+			}
+			forms = append(forms, items[1])
+			tags = append(tags, tag)
+		} else {
+			items := strings.Fields(line)
+			if len(items) < 3 {
+				return fmt.Errorf("line %d: expected form, lemma and tag", n)
+			}
+			forms = append(forms, items[0])
+			tags = append(tags, items[2])
+		}
+	}
+	flush()
+	return scanner.Err()
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -8,6 +8,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 const UNOBS_INITIAL_STATE = "0.x"
@@ -232,15 +233,16 @@ type HMMTagger struct {
 	probInitial    float64
 	probUnobserved float64
 	pA_cache       map[string]float64
+	pA_mutex       *sync.RWMutex
 	kbest          int
 	c              [3]float64
+	tagsetFile     string
 }
 
 func NewHMMTagger(hmmFile string, rtk bool, force int, kb int) *HMMTagger {
 
 	var prob, coef float64
 	var nom1, aux, ftags string
-	path := hmmFile[0:strings.LastIndex(hmmFile, "/")]
 
 	this := HMMTagger{
 		PTag:      make(map[string]float64),
@@ -250,11 +252,18 @@ func NewHMMTagger(hmmFile string, rtk bool, force int, kb int) *HMMTagger {
 		PWord:     make(map[string]float64),
 		Forbidden: make(map[string][]string),
 		pA_cache:  make(map[string]float64),
+		pA_mutex:  new(sync.RWMutex),
 		kbest:     kb,
 	}
 
 	this.POSTagger.force = force
 
+	if this.readBinary(hmmFile) {
+		this.Tags = NewTagset(tagsetPath(hmmFile, this.tagsetFile))
+		TRACE(3, "Analyzer loaded from "+BinaryFile(hmmFile), MOD_HMM)
+		return &this
+	}
+
 	cfg := NewConfigFile(false, "##")
 	cfg.AddSection("Tag", UNIGRAM)
 	cfg.AddSection("Bigram", BIGRAM)
@@ -388,8 +397,9 @@ func NewHMMTagger(hmmFile string, rtk bool, force int, kb int) *HMMTagger {
 		case TAGSET:
 			{
 				ftags = items[0]
-				TRACE(3, "Loading tagset file "+path+"/"+ftags, MOD_HMM)
-				this.Tags = NewTagset(path + "/" + strings.Replace(ftags, "./", "", -1))
+				this.tagsetFile = ftags
+				TRACE(3, "Loading tagset file "+tagsetPath(hmmFile, ftags), MOD_HMM)
+				this.Tags = NewTagset(tagsetPath(hmmFile, ftags))
 				break
 			}
 		default:
@@ -403,6 +413,79 @@ func NewHMMTagger(hmmFile string, rtk bool, force int, kb int) *HMMTagger {
 	return &this
 }
 
+// hmmData is the compiled form of the tagger model. Bigram keyed maps are
+// stored by their "t1.t2" names.
+type hmmData struct {
+	PTag           map[string]float64
+	PBg            map[string]float64
+	PTrg           map[string]float64
+	PInitial       map[string]float64
+	PWord          map[string]float64
+	Forbidden      map[string][]string
+	ProbInitial    float64
+	ProbUnobserved float64
+	C              [3]float64
+	TagsetFile     string
+}
+
+func (this *HMMTagger) readBinary(hmmFile string) bool {
+	var data hmmData
+	if !readBinary(hmmFile, BINARY_HMM, &data) {
+		return false
+	}
+	if data.PTag != nil {
+		this.PTag = data.PTag
+	}
+	if data.PTrg != nil {
+		this.PTrg = data.PTrg
+	}
+	if data.PWord != nil {
+		this.PWord = data.PWord
+	}
+	if data.Forbidden != nil {
+		this.Forbidden = data.Forbidden
+	}
+	for k, prob := range data.PBg {
+		bg := strings.Split(k, ".")
+		this.PBg.Insert(&Bigram{bg[0], bg[1]}, prob)
+	}
+	for k, prob := range data.PInitial {
+		bg := strings.Split(k, ".")
+		this.PInitial.Insert(&Bigram{bg[0], bg[1]}, prob)
+	}
+	this.probInitial = data.ProbInitial
+	this.probUnobserved = data.ProbUnobserved
+	this.c = data.C
+	this.tagsetFile = data.TagsetFile
+	return true
+}
+
+// Compile writes the compiled version of the model loaded from hmmFile,
+// which also depends on the tagset for its Forbidden keys.
+func (this *HMMTagger) Compile(hmmFile string) error {
+	data := hmmData{
+		PTag:           this.PTag,
+		PBg:            make(map[string]float64),
+		PTrg:           this.PTrg,
+		PInitial:       make(map[string]float64),
+		PWord:          this.PWord,
+		Forbidden:      this.Forbidden,
+		ProbInitial:    this.probInitial,
+		ProbUnobserved: this.probUnobserved,
+		C:              this.c,
+		TagsetFile:     this.tagsetFile,
+	}
+	this.PBg.Do(func(k interface{}, v interface{}) {
+		bg := k.(*Bigram)
+		data.PBg[bg.First+"."+bg.Second] = v.(float64)
+	})
+	this.PInitial.Do(func(k interface{}, v interface{}) {
+		bg := k.(*Bigram)
+		data.PInitial[bg.First+"."+bg.Second] = v.(float64)
+	})
+	return writeBinary(hmmFile, BINARY_HMM, &data, tagsetPath(hmmFile, this.tagsetFile))
+}
+
 func (this *HMMTagger) isForbidden(trig string, w *list.Element) bool {
 	if len(this.Forbidden) == 0 {
 		return false
@@ -423,8 +506,10 @@ func (this *HMMTagger) ProbA_log(state_i *Bigram, state_j *Bigram, w *list.Eleme
 		prob = 0
 		forb = true
 	} else {
-		var d float64 = 0.0
-		d = this.pA_cache[t1t2t3]
+		// the cache is shared by the sentences tagged concurrently
+		this.pA_mutex.RLock()
+		d := this.pA_cache[t1t2t3]
+		this.pA_mutex.RUnlock()
 		if d != 0 {
 			TRACE(5, fmt.Sprintf("      cached pa(%s)= %f\n", t1t2t3, d), MOD_HMM)
 			return d
@@ -451,7 +536,9 @@ func (this *HMMTagger) ProbA_log(state_i *Bigram, state_j *Bigram, w *list.Eleme
 
 	prob = math.Log(prob)
 	if !forb {
+		this.pA_mutex.Lock()
 		this.pA_cache[t1t2t3] = prob
+		this.pA_mutex.Unlock()
 	}
 
 	return prob
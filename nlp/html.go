@@ -0,0 +1,222 @@
+package nlp
+
+import (
+	"bytes"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/transform"
+)
+
+// elements whose content is not text
+var htmlSkipped = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"svg":      true,
+}
+
+// elements that start and end a block, turned into paragraph breaks
+var htmlBlocks = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"caption": true, "dd": true, "details": true, "div": true, "dl": true,
+	"dt": true, "fieldset": true, "figcaption": true, "figure": true,
+	"footer": true, "form": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"legend": true, "li": true, "main": true, "nav": true, "ol": true,
+	"option": true, "p": true, "pre": true, "section": true, "summary": true,
+	"table": true, "tbody": true, "td": true, "tfoot": true, "th": true,
+	"thead": true, "title": true, "tr": true, "ul": true, "body": true,
+}
+
+// elements whose blanks are kept as they are
+var htmlPreformatted = map[string]bool{
+	"pre":      true,
+	"textarea": true,
+}
+
+// HTMLText is the text of an HTML page, with the position in the page of
+// every byte of the text.
+type HTMLText struct {
+	Text   string
+	starts []int
+	ends   []int
+}
+
+// StripHTML extracts the text of an HTML page. Tags, comments, scripts and
+// styles are removed, entities are decoded, runs of blanks become a space
+// (except in preformatted elements), and block elements are separated by
+// blank lines so the splitter ends sentences and paragraphs at them.
+func StripHTML(page string) *HTMLText {
+	this := &HTMLText{}
+	var text bytes.Buffer
+	pre := 0
+
+	emit := func(s string, start int, end int) {
+		text.WriteString(s)
+		for i := 0; i < len(s); i++ {
+			this.starts = append(this.starts, start)
+			this.ends = append(this.ends, end)
+		}
+	}
+
+	i := 0
+	for i < len(page) {
+		c := page[i]
+		switch {
+		case c == '<' && strings.HasPrefix(page[i:], "<!--"):
+			end := strings.Index(page[i+4:], "-->")
+			if end < 0 {
+				i = len(page)
+			} else {
+				i += 4 + end + 3
+			}
+
+		case c == '<' && i+1 < len(page) && (page[i+1] == '!' || page[i+1] == '?'):
+			i = tagEnd(page, i)
+
+		case c == '<' && i+1 < len(page) && (isASCIILetter(page[i+1]) || page[i+1] == '/'):
+			end := tagEnd(page, i)
+			name, closing := tagName(page[i:end])
+			switch {
+			case !closing && htmlSkipped[name]:
+				// skip up to the closing tag of the element
+				close := strings.Index(strings.ToLower(page[end:]), "</"+name)
+				if close < 0 {
+					end = len(page)
+				} else {
+					end = tagEnd(page, end+close)
+				}
+			case name == "br":
+				emit("\n", i, end)
+			case htmlBlocks[name]:
+				emit("\n\n", i, end)
+			}
+			if htmlPreformatted[name] {
+				if closing && pre > 0 {
+					pre--
+				} else if !closing {
+					pre++
+				}
+			}
+			i = end
+
+		case c == '&':
+			end := strings.IndexByte(page[i:], ';')
+			if end > 0 && end < 32 {
+				decoded := html.UnescapeString(page[i : i+end+1])
+				if decoded != page[i:i+end+1] {
+					emit(strings.Replace(decoded, "\u00a0", " ", -1), i, i+end+1)
+					i += end + 1
+					continue
+				}
+			}
+			emit("&", i, i+1)
+			i++
+
+		case pre == 0 && c < utf8.RuneSelf && WhiteSpace(c):
+			end := i
+			for end < len(page) && page[end] < utf8.RuneSelf && WhiteSpace(page[end]) {
+				end++
+			}
+			emit(" ", i, end)
+			i = end
+
+		default:
+			_, size := utf8.DecodeRuneInString(page[i:])
+			emit(page[i:i+size], i, i+size)
+			i += size
+		}
+	}
+
+	this.Text = text.String()
+	return this
+}
+
+// DecodeHTML decodes a page to UTF-8 from the charset of contentType or, as
+// Fetch does, of the page itself. With the page it returns the position in
+// the raw page of every byte of the decoded one, followed by the length of
+// the raw page, or nil when the page is already valid UTF-8.
+func DecodeHTML(raw []byte, contentType string) (string, []int, error) {
+	encoding, name, _ := charset.DetermineEncoding(raw, contentType)
+	if name == "utf-8" && utf8.Valid(raw) {
+		return string(raw), nil, nil
+	}
+
+	decoder := encoding.NewDecoder()
+	var page bytes.Buffer
+	offsets := make([]int, 0, len(raw)+1)
+	dst := make([]byte, 64)
+	for i := 0; i < len(raw); {
+		// give the decoder one more byte until it decodes a character, so
+		// its bytes in the page are known
+		n := 1
+		for {
+			nDst, nSrc, err := decoder.Transform(dst, raw[i:i+n], i+n == len(raw))
+			if nSrc > 0 {
+				page.Write(dst[:nDst])
+				for j := 0; j < nDst; j++ {
+					offsets = append(offsets, i)
+				}
+				i += nSrc
+				break
+			}
+			if i+n == len(raw) || (err != nil && err != transform.ErrShortSrc) {
+				if err == nil {
+					err = transform.ErrShortSrc
+				}
+				return "", nil, err
+			}
+			n++
+		}
+	}
+	return page.String(), append(offsets, len(raw)), nil
+}
+
+// Span returns the position in the page of the text from start to finish,
+// or -1, -1 when it is out of the text.
+func (this *HTMLText) Span(start int, finish int) (int, int) {
+	if start < 0 || finish > len(this.starts) || start >= finish {
+		return -1, -1
+	}
+	return this.starts[start], this.ends[finish-1]
+}
+
+// tagEnd returns the position after the tag starting at i, skipping quoted
+// attribute values.
+func tagEnd(page string, i int) int {
+	quote := byte(0)
+	for j := i + 1; j < len(page); j++ {
+		c := page[j]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+		} else if c == '"' || c == '\'' {
+			quote = c
+		} else if c == '>' {
+			return j + 1
+		}
+	}
+	return len(page)
+}
+
+// tagName returns the lowercase name of a tag and whether it is a closing
+// tag.
+func tagName(tag string) (string, bool) {
+	tag = strings.TrimPrefix(tag, "<")
+	closing := strings.HasPrefix(tag, "/")
+	tag = strings.TrimPrefix(tag, "/")
+	end := 0
+	for end < len(tag) && (isASCIILetter(tag[end]) || (tag[end] >= '0' && tag[end] <= '9')) {
+		end++
+	}
+	return strings.ToLower(tag[:end]), closing
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,72 @@
+package nlp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripHTML(t *testing.T) {
+	tests := []struct {
+		page string
+		text string
+	}{
+		{"<p>Fish &amp; chips&nbsp;here &#233;&unknown; &</p>", "\n\nFish & chips here é&unknown; &\n\n"},
+		{"A<script>if (a < b) { x() }</script>B<style>p { }</style>C<!-- <p> -->D", "ABCD"},
+		{"<p>one\n\t two</p><pre>a  b\n c</pre>", "\n\none two\n\n\n\na  b\n c\n\n"},
+		{"<div>a</div><span>b</span><br>c<li>d", "\n\na\n\nb\nc\n\nd"},
+		{`<a title="x > y">link</a>`, "link"},
+	}
+	for _, test := range tests {
+		if text := StripHTML(test.page).Text; text != test.text {
+			t.Errorf("%q: got %q, expected %q", test.page, text, test.text)
+		}
+	}
+}
+
+func TestHTMLSpan(t *testing.T) {
+	page := "<p>Fish &amp; <b>chips</b></p>"
+	stripped := StripHTML(page)
+	for _, word := range []string{"Fish", "&", "chips"} {
+		at := strings.Index(stripped.Text, word)
+		start, finish := stripped.Span(at, at+len(word))
+		if start < 0 {
+			t.Errorf("%s: no span", word)
+			continue
+		}
+		original := page[start:finish]
+		if word == "&" {
+			word = "&amp;"
+		}
+		if original != word {
+			t.Errorf("got span %q, expected %q", original, word)
+		}
+	}
+	if start, finish := stripped.Span(0, len(stripped.Text)+1); start != -1 || finish != -1 {
+		t.Errorf("span out of the text: got %d, %d", start, finish)
+	}
+}
+
+// TestDecodeHTML decodes an ISO-8859-1 page and checks that the offsets take
+// a span of the decoded page back to the raw one.
+func TestDecodeHTML(t *testing.T) {
+	raw := []byte("<p>Caf\xe9 con le\xf1a.</p>")
+	page, offsets, err := DecodeHTML(raw, "text/html; charset=iso-8859-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page != "<p>Café con leña.</p>" {
+		t.Fatalf("got %q", page)
+	}
+	if len(offsets) != len(page)+1 {
+		t.Fatalf("got %d offsets for %d bytes", len(offsets), len(page))
+	}
+	at := strings.Index(page, "leña")
+	if original := string(raw[offsets[at]:offsets[at+len("leña")]]); original != "le\xf1a" {
+		t.Errorf("got raw span %q", original)
+	}
+
+	page, offsets, err = DecodeHTML([]byte("<p>Café</p>"), "text/html; charset=utf-8")
+	if err != nil || page != "<p>Café</p>" || offsets != nil {
+		t.Errorf("UTF-8 page: got %q, %v, %v", page, offsets, err)
+	}
+}
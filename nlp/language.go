@@ -133,6 +133,7 @@ type Word struct {
 	ambiguousMw   bool
 	alternatives  *list.List
 	start, finish int
+	lineBreaks    int
 	inDict        bool
 	locked        bool
 	position      int
@@ -141,6 +142,7 @@ type Word struct {
 	ALL           int
 	user          []string
 	expired       bool
+	synset        *Synset
 }
 
 func NewWord() *Word {
@@ -270,6 +272,10 @@ func (this *Word) setSpan(start int, finish int) { this.start = start; this.fini
 func (this *Word) getSpanStart() int             { return this.start }
 func (this *Word) getSpanFinish() int            { return this.finish }
 
+// line breaks in the blanks before the word
+func (this *Word) setLineBreaks(n int) { this.lineBreaks = n }
+func (this *Word) getLineBreaks() int  { return this.lineBreaks }
+
 func (this *Word) findTagMatch(re *regexp.Regexp) bool {
 	found := false
 	for an := this.Front(); an != nil && !found; an = an.Next() {
@@ -379,6 +385,9 @@ func (this *Word) setSenses(ls *list.List, k int) {
 	this.selectedBegin(k).Value.(*Analysis).setSenses(ls)
 }
 
+func (this *Word) getSynset() *Synset    { return this.synset }
+func (this *Word) setSynset(syn *Synset) { this.synset = syn }
+
 func (this *Word) getPosition() int      { return this.position }
 func (this *Word) setPosition(i int)     { this.position = i }
 func (this *Word) foundInDict() bool     { return this.inDict }
@@ -420,6 +429,7 @@ func (this *Word) sort() {
 type Sentence struct {
 	*list.List
 	sentID   string
+	parID    int
 	wpos     []*Word
 	pts      map[int]*ParseTree
 	status   *list.List
@@ -438,6 +448,10 @@ func NewSentence() *Sentence {
 func (this *Sentence) setSentenceID(sid string) { this.sentID = sid }
 func (this *Sentence) getSentenceID() string    { return this.sentID }
 
+// paragraph of the sentence in the text, from 1
+func (this *Sentence) setParagraphID(id int) { this.parID = id }
+func (this *Sentence) getParagraphID() int   { return this.parID }
+
 func (this *Sentence) setParseTree(tr *ParseTree, k int) {
 	this.pts[k] = tr
 	this.pts[k].rebuildNodeIndex()
@@ -22,6 +22,9 @@ func NewLexer(rs []*Pair) *Lexer {
 	}
 
 	this.rules = rs
+	for _, rule := range this.rules {
+		rule.first.(*regexp.Regexp).Longest()
+	}
 
 	return &this
 }
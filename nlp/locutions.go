@@ -68,14 +68,18 @@ func NewLocutions(locFile string) *Locutions {
 		cfg.AddSection("Multiwords", LOCUTIONS_MULTIWORDS)
 		cfg.AddSection("OnlySelected", LOCUTIONS_ONLYSELECTED)
 	*/
-	filestr, err := ioutil.ReadFile(locFile)
-	if err != nil {
-		LOG.Panic("Error opening file " + locFile)
-	}
-	lines := strings.Split(string(filestr), "\n")
+	if this.readBinary(locFile) {
+		LOG.Trace("Multiwords loaded from " + BinaryFile(locFile))
+	} else {
+		filestr, err := ioutil.ReadFile(locFile)
+		if err != nil {
+			LOG.Panic("Error opening file " + locFile)
+		}
+		lines := strings.Split(string(filestr), "\n")
 
-	for _, line := range lines {
-		this.addLocution(line)
+		for _, line := range lines {
+			this.addLocution(line)
+		}
 	}
 
 	/*
@@ -482,3 +486,35 @@ func (this *Locutions) analyze(se *Sentence) {
 		se.rebuildWordIndex()
 	}
 }
+
+// locutionsData is the compiled form of the multiwords.
+type locutionsData struct {
+	Locut    map[string]string
+	Prefixes []string
+}
+
+func (this *Locutions) readBinary(locFile string) bool {
+	var data locutionsData
+	if !readBinary(locFile, BINARY_LOCUTIONS, &data) {
+		return false
+	}
+	if data.Locut != nil {
+		this.locut = data.Locut
+	}
+	for _, prefix := range data.Prefixes {
+		this.prefixes.Add(prefix)
+	}
+	return true
+}
+
+// Compile writes the compiled version of the multiwords loaded from locFile.
+func (this *Locutions) Compile(locFile string) error {
+	data := locutionsData{
+		Locut:    this.locut,
+		Prefixes: make([]string, 0, this.prefixes.Size()),
+	}
+	for _, prefix := range this.prefixes.List() {
+		data.Prefixes = append(data.Prefixes, prefix.(string))
+	}
+	return writeBinary(locFile, BINARY_LOCUTIONS, &data)
+}
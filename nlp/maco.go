@@ -6,6 +6,7 @@ type MacoOptions struct {
 	Decimal, Thousand                                                                                                                 string
 	ProbabilityThreshold                                                                                                              float64
 	InverseDict, RetokContractions                                                                                                    bool
+	UserDictionaryFile                                                                                                                string
 }
 
 func NewMacoOptions(lang string) *MacoOptions {
@@ -25,6 +26,7 @@ func NewMacoOptions(lang string) *MacoOptions {
 		ProbabilityThreshold: 0.001,
 		InverseDict:          false,
 		RetokContractions:    true,
+		UserDictionaryFile:   "",
 	}
 }
 
@@ -40,6 +42,10 @@ func (this *MacoOptions) SetDataFiles(usr, pun, dic, aff, comp, loc, nps, qty, p
 	this.CompoundFile = comp
 }
 
+func (this *MacoOptions) SetUserDictionary(file string) {
+	this.UserDictionaryFile = file
+}
+
 func (this *MacoOptions) SetNumericalPoint(dec string, tho string) {
 	this.Decimal = dec
 	this.Thousand = tho
@@ -94,6 +100,11 @@ func NewMaco(opts *MacoOptions) *Maco {
 	if opts.DictionaryFile != "" {
 		this.dic = NewDictionary(opts.Lang, opts.DictionaryFile, opts.AffixFile, opts.CompoundFile, opts.InverseDict, opts.RetokContractions)
 		this.DictionarySearch = true
+		if opts.UserDictionaryFile != "" {
+			if err := this.dic.LoadUserDictionary(opts.UserDictionaryFile); err != nil {
+				LOG.Error("Error loading user dictionary " + opts.UserDictionaryFile + ": " + err.Error())
+			}
+		}
 	}
 
 	if opts.LocutionsFile != "" {
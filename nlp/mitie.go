@@ -93,8 +93,15 @@ func (this *MITIE) Release() {
 	C.mitie_free(unsafe.Pointer(this.ner))
 }
 
+// Process extracts the entities of a text. At most as many texts as the
+// semaphore allows are processed at once by the extractor.
 func (this *MITIE) Process(body string) *list.List {
-	tokens := C.mitie_tokenize(C.CString(body))
+	this.sem.Acquire()
+	defer this.sem.Release()
+
+	cbody := C.CString(body)
+	defer C.free(unsafe.Pointer(cbody))
+	tokens := C.mitie_tokenize(cbody)
 	if tokens == nil {
 		return nil
 	}
@@ -3,13 +3,16 @@ package nlp
 import (
 	"container/list"
 	"os"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/fatih/set"
 	"github.com/kdar/factorlog"
 
 	"github.com/advancedlogic/go-freeling/models"
 	"github.com/advancedlogic/go-freeling/wordnet"
+	"github.com/advancedlogic/go-freeling/writer"
 )
 
 var LOG *factorlog.FactorLog
@@ -28,7 +31,7 @@ const (
 
 func init() {
 	frmt := `%{Color "red" "ERROR"}%{Color "yellow" "WARN"}%{Color "green" "INFO"}%{Color "cyan" "DEBUG"}%{Color "blue" "TRACE"}[%{Date} %{Time}] [%{SEVERITY}:%{File}:%{Line}] %{Message}%{Color "reset"}`
-	LOG = factorlog.New(os.Stdout, factorlog.NewStdFormatter(frmt))
+	LOG = factorlog.New(os.Stderr, factorlog.NewStdFormatter(frmt))
 	LOG.SetMinMaxSeverity(factorlog.PANIC, factorlog.TRACE)
 }
 
@@ -40,17 +43,25 @@ type NLPOptions struct {
 	SplitterFile      string
 	MorfoOptions      *MacoOptions
 	TaggerFile        string
+	TagsetFile        string
 	ShallowParserFile string
 	SenseFile         string
 	UKBFile           string
 	DisambiguatorFile string
-	Status            func()
+	SentimentFile     string
+	// sentences of a document analyzed in parallel, one at a time when 1
+	Workers int
+	// fetches the pages of documents with a url and no content, a default
+	// crawler when nil
+	Crawler *Crawler
+	Status  func()
 }
 
 func NewNLPOptions(dataPath string, lang string, f func()) *NLPOptions {
 	return &NLPOptions{
 		DataPath: dataPath,
 		Lang:     lang,
+		Workers:  runtime.NumCPU(),
 		Status:   f,
 	}
 }
@@ -61,13 +72,17 @@ type NLPEngine struct {
 	splitter      *Splitter
 	morfo         *Maco
 	tagger        *HMMTagger
+	tags          *TagSet
 	grammar       *Grammar
 	shallowParser *ChartParser
 	sense         *Senses
 	dsb           *UKB
 	disambiguator *Disambiguator
+	sentiment     *Sentiment
+	domains       *Domains
 	filter        *set.Set
 	mitie         *MITIE
+	crawler       *Crawler
 	WordNet       *wordnet.WN
 }
 
@@ -78,6 +93,11 @@ func NewNLPEngine(options *NLPOptions) *NLPEngine {
 
 	LOG.SetMinMaxSeverity(factorlog.PANIC, options.Severity)
 
+	this.crawler = options.Crawler
+	if this.crawler == nil {
+		this.crawler = NewDefaultCrawler()
+	}
+
 	if options.TokenizerFile != "" {
 		this.tokenizer = NewTokenizer(options.DataPath + "/" + options.Lang + "/" + options.TokenizerFile)
 		this.options.Status()
@@ -103,6 +123,10 @@ func NewNLPEngine(options *NLPOptions) *NLPEngine {
 		this.options.Status()
 	}
 
+	if options.TagsetFile != "" {
+		this.tags = NewTagset(options.DataPath + "/" + options.Lang + "/" + options.TagsetFile)
+	}
+
 	if options.ShallowParserFile != "" {
 		this.grammar = NewGrammar(options.DataPath + "/" + options.Lang + "/" + options.ShallowParserFile)
 		this.shallowParser = NewChartParser(this.grammar)
@@ -123,6 +147,21 @@ func NewNLPEngine(options *NLPOptions) *NLPEngine {
 		this.options.Status()
 	}
 
+	// sentiment scores come from the synsets of the disambiguator, or from
+	// the lexicon of the sentiment file
+	if options.SentimentFile != "" {
+		this.sentiment = NewSentiment(options.Lang, options.DataPath+"/"+options.Lang+"/"+options.SentimentFile)
+		this.options.Status()
+	} else if this.disambiguator != nil {
+		this.sentiment = NewSentiment(options.Lang, "")
+	}
+
+	if this.sense != nil {
+		this.domains = NewDomains(this.sense.semdb)
+	} else if this.disambiguator != nil {
+		this.domains = NewDomains(nil)
+	}
+
 	this.mitie = NewMITIE(options.DataPath + "/" + options.Lang + "/mitie/ner_model.dat")
 	this.options.Status()
 	return &this
@@ -146,8 +185,14 @@ func (this *NLPEngine) Workflow(document *models.DocumentEntity, output chan *mo
 	content := document.Content
 
 	if url != "" && content == "" {
-		crawler := NewDefaultCrawler()
-		article := crawler.Analyze(url)
+		article, err := this.crawler.Analyze(url)
+		if err != nil {
+			LOG.Warn(err.Error())
+			document.SetError(CrawlErrorEntity(url, err))
+			document.Entities = list.New()
+			output <- document
+			return
+		}
 		document.Title = article.Title
 		document.Description = article.MetaDescription
 		document.Keywords = article.MetaKeywords
@@ -155,7 +200,28 @@ func (this *NLPEngine) Workflow(document *models.DocumentEntity, output chan *mo
 		document.Content = article.CleanedText
 	}
 
-	body := StringsAppend(document.Title, document.Description, document.Keywords, document.Content)
+	var page *HTMLText
+	if document.HTML != "" && content == "" {
+		page = StripHTML(document.HTML)
+		document.Content = page.Text
+	}
+
+	// title, description, keywords and content are separate paragraphs
+	body := ""
+	contentOffset := 0
+	for i, part := range []string{document.Title, document.Description, document.Keywords, document.Content} {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		if body != "" {
+			body += "\n\n"
+		}
+		if i == 3 {
+			contentOffset = len(body)
+		}
+		body += part
+	}
+	document.SetBody(body)
 
 	if this.tokenizer != nil {
 		this.tokenizer.Tokenize(body, 0, tokens)
@@ -169,59 +235,46 @@ func (this *NLPEngine) Workflow(document *models.DocumentEntity, output chan *mo
 		this.splitter.CloseSession(sid)
 	}
 
-	for ss := sentences.Front(); ss != nil; ss = ss.Next() {
-		s := ss.Value.(*Sentence)
-		if this.morfo != nil {
-			this.morfo.Analyze(s)
-		}
-		if this.sense != nil {
-			this.sense.Analyze(s)
-		}
-		if this.tagger != nil {
-			this.tagger.Analyze(s)
-		}
-		if this.shallowParser != nil {
-			this.shallowParser.Analyze(s)
-		}
-	}
+	this.analyzeSentences(sentences)
 
 	if this.dsb != nil {
 		this.dsb.Analyze(sentences)
 	}
 
+	if this.disambiguator != nil {
+		this.disambiguator.Analyze(sentences)
+	}
+
+	ontology := document.HasFlag(models.FLAG_ONTOLOGY)
 	entities := make(map[string]int64)
+	sentiments := make([]*models.SentimentEntity, 0)
 
 	for ss := sentences.Front(); ss != nil; ss = ss.Next() {
-		se := models.NewSentenceEntity()
-		body := ""
-		s := ss.Value.(*Sentence)
-		for ww := s.Front(); ww != nil; ww = ww.Next() {
-			w := ww.Value.(*Word)
-			a := w.Front().Value.(*Analysis)
-
-			base := w.getForm()
-			lemma := a.getLemma()
-			pos := a.getTag()
-			props := a.getProb()
-			annotation := this.WordNet.Annotate(base, pos)
-
-			te := models.NewTokenEntity(base, lemma, pos, props, annotation)
-			if pos == TAG_NP {
-				entities[base]++
-			}
-			body += base + " "
-			se.AddTokenEntity(te)
+		se := this.sentenceEntity(ss.Value.(*Sentence), body, 0, ontology, entities)
+		if sentiment := se.GetSentiment(); sentiment != nil {
+			sentiments = append(sentiments, sentiment)
 		}
-		body = strings.Trim(body, " ")
-		se.SetBody(body)
-		se.SetSentence(s)
-
 		document.AddSentenceEntity(se)
 	}
 
+	if page != nil {
+		mapHTMLSpans(document, page, contentOffset)
+	}
+
+	if this.sentiment != nil {
+		document.SetSentiment(this.sentiment.Aggregate(sentiments))
+	}
+
+	if this.domains != nil {
+		document.SetDomains(this.domains.Profile(sentences, document.Domains))
+	}
+
 	tempEntities := set.New(set.ThreadSafe).(*set.Set)
 
 	mitieEntities := this.mitie.Process(body)
+	if mitieEntities == nil {
+		mitieEntities = list.New()
+	}
 	for e := mitieEntities.Front(); e != nil; e = e.Next() {
 		entity := e.Value.(*models.Entity)
 		tempEntities.Add(entity.GetValue())
@@ -238,31 +291,240 @@ func (this *NLPEngine) Workflow(document *models.DocumentEntity, output chan *mo
 	output <- document
 }
 
-func (this *NLPEngine) PrintList(document *models.DocumentEntity) {
-	ls := document.Sentences()
-	for l := ls.Front(); l != nil; l = l.Next() {
-		for w := l.Value.(*Sentence).Front(); w != nil; w = w.Next() {
-			item := w.Value.(*Word).getForm() + ":"
-			for a := w.Value.(*Word).Front(); a != nil; a = a.Next() {
-				if a.Value.(*Analysis).isSelected(0) {
-					item += a.Value.(*Analysis).getTag()
-				}
+// sentenceEntity converts an analyzed sentence. body is the text analyzed
+// from offset on, and the forms tagged as proper nouns are counted in
+// entities unless it is nil.
+func (this *NLPEngine) sentenceEntity(s *Sentence, body string, offset int, ontology bool, entities map[string]int64) *models.SentenceEntity {
+	se := models.NewSentenceEntity()
+	forms := ""
+	index := make(map[*Word]int)
+	for ww := s.Front(); ww != nil; ww = ww.Next() {
+		w := ww.Value.(*Word)
+		index[w] = len(index)
+		base := w.getForm()
+		lemma, pos, props := "", "", 0.0
+		var a *Analysis
+		if w.getNAnalysis() > 0 {
+			a = w.Front().Value.(*Analysis)
+			lemma = a.getLemma()
+			pos = a.getTag()
+			props = a.getProb()
+		}
+		annotation := this.WordNet.Annotate(lemma, base, this.wordNetPOS(pos))
+
+		te := models.NewTokenEntity(base, lemma, pos, props, annotation)
+		te.SetSpan(w.getSpanStart(), w.getSpanFinish())
+		te.SetInDict(w.foundInDict())
+		for aa := w.Front(); aa != nil; aa = aa.Next() {
+			analysis := aa.Value.(*Analysis)
+			te.AddAnalysis(&models.AnalysisEntity{
+				Lemma:    analysis.getLemma(),
+				Tag:      analysis.getTag(),
+				Prob:     analysis.getProb(),
+				Selected: analysis.isSelected(0),
+			})
+		}
+		for mw := w.getWordsMw().Front(); mw != nil; mw = mw.Next() {
+			component := mw.Value.(*Word)
+			ce := models.NewTokenEntity(component.getForm(), component.getForm(), pos, props, nil)
+			ce.SetSpan(component.getSpanStart(), component.getSpanFinish())
+			te.AddComponent(ce)
+		}
+		if tags := this.tagset(); tags != nil && pos != "" {
+			features := tags.GetMSDFeatures(pos)
+			te.SetMorphology(tags.GetShortTag(pos), features, UniversalPOS(features), UniversalFeats(features))
+		}
+		if syn := w.getSynset(); syn != nil {
+			te.SetSynset(syn.toEntity())
+		}
+		if a != nil {
+			for l := a.getSenses().Front(); l != nil; l = l.Next() {
+				pair := l.Value.(FloatPair)
+				te.AddSense(this.senseEntity(pair.first, pair.second, ontology))
 			}
-			println(item)
+		}
+		if pos == TAG_NP && entities != nil {
+			entities[base]++
+		}
+		forms += base + " "
+		se.AddTokenEntity(te)
+	}
+	se.SetBody(strings.Trim(forms, " "))
+	if s.Len() > 0 {
+		start := s.Front().Value.(*Word).getSpanStart()
+		finish := s.Back().Value.(*Word).getSpanFinish()
+		if start >= offset && start <= finish && finish-offset <= len(body) {
+			se.SetText(body[start-offset:finish-offset], start)
 		}
 	}
+	se.SetParagraph(s.getParagraphID())
+	se.SetSentence(s)
+	if tr := s.getParseTree(0); tr != nil && !tr.Empty() {
+		se.SetTree(new(Output).treeEntity(tr.begin(), index))
+	}
+
+	if this.sentiment != nil {
+		se.SetSentiment(this.sentiment.Analyze(s))
+	}
+	return se
 }
 
-func (this *NLPEngine) PrintTree(document *models.DocumentEntity) {
-	ls := document.Sentences()
-	for l := ls.Front(); l != nil; l = l.Next() {
-		tr := l.Value.(*models.SentenceEntity).GetSentence().(*Sentence).pts[0]
-		output := new(Output)
-		out := ""
+// mapHTMLSpans sets the position in the HTML page of the tokens of its text,
+// which starts at offset in the document body. For a page decoded from
+// another charset, the position is taken back to the raw page.
+func mapHTMLSpans(document *models.DocumentEntity, page *HTMLText, offset int) {
+	raw := document.GetHTMLOffsets()
+	mapSpan := func(te *models.TokenEntity) {
+		start, finish := page.Span(te.GetSpanStart()-offset, te.GetSpanFinish()-offset)
+		if start < 0 {
+			return
+		}
+		if raw != nil {
+			start, finish = raw[start], raw[finish]
+		}
+		te.SetHTMLSpan(start, finish)
+	}
+	for s := document.Sentences().Front(); s != nil; s = s.Next() {
+		for t := s.Value.(*models.SentenceEntity).Tokens().Front(); t != nil; t = t.Next() {
+			te := t.Value.(*models.TokenEntity)
+			mapSpan(te)
+			for _, ce := range te.GetComponents() {
+				mapSpan(ce)
+			}
+		}
+	}
+}
+
+// analyzeSentences runs the sentence level modules on every sentence, with
+// up to Workers sentences at once. Sentences are analyzed in place, so their
+// order is kept. A panic in a worker is raised again once all of them are
+// done.
+func (this *NLPEngine) analyzeSentences(sentences *list.List) {
+	workers := this.options.Workers
+	if workers > sentences.Len() {
+		workers = sentences.Len()
+	}
+	if workers <= 1 {
+		for ss := sentences.Front(); ss != nil; ss = ss.Next() {
+			this.analyzeSentence(ss.Value.(*Sentence))
+		}
+		return
+	}
+
+	jobs := make(chan *Sentence)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var failure interface{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range jobs {
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							mutex.Lock()
+							if failure == nil {
+								failure = r
+							}
+							mutex.Unlock()
+						}
+					}()
+					this.analyzeSentence(s)
+				}()
+			}
+		}()
+	}
+	for ss := sentences.Front(); ss != nil; ss = ss.Next() {
+		jobs <- ss.Value.(*Sentence)
+	}
+	close(jobs)
+	wg.Wait()
+
+	if failure != nil {
+		panic(failure)
+	}
+}
 
-		output.PrintTree(&out, tr.begin(), 0)
+func (this *NLPEngine) analyzeSentence(s *Sentence) {
+	if this.morfo != nil {
+		this.morfo.Analyze(s)
+	}
+	if this.sense != nil {
+		this.sense.Analyze(s)
+	}
+	if this.tagger != nil {
+		this.tagger.Analyze(s)
+	}
+	if this.shallowParser != nil {
+		this.shallowParser.Analyze(s)
+	}
+}
 
-		LOG.Trace(out)
-		println(out)
+// Release frees the resources of the engine not managed by Go.
+func (this *NLPEngine) Release() {
+	if this.mitie != nil {
+		this.mitie.Release()
 	}
 }
+
+// Dictionary returns the dictionary of the morphological analyzer, nil if
+// the pipeline does not reach it.
+func (this *NLPEngine) Dictionary() *Dictionary {
+	if this.morfo == nil {
+		return nil
+	}
+	return this.morfo.dic
+}
+
+// tagset returns the tagset of the language, falling back to the one loaded
+// by the tagger or the probability module when no TagsetFile was given.
+func (this *NLPEngine) tagset() *TagSet {
+	if this.tags != nil {
+		return this.tags
+	}
+	if this.tagger != nil {
+		return this.tagger.Tags
+	}
+	if this.morfo != nil && this.morfo.prob != nil {
+		return this.morfo.prob.Tags
+	}
+	return nil
+}
+
+func (this *NLPEngine) wordNetPOS(tag string) string {
+	if tags := this.tagset(); tags != nil {
+		return tags.GetWordNetPOS(tag)
+	}
+	return ""
+}
+
+func (this *NLPEngine) senseEntity(id string, rank float64, ontology bool) *models.SenseEntity {
+	entity := &models.SenseEntity{Id: id}
+	if ontology && this.sense != nil {
+		entity = this.sense.semdb.getSenseInfo(id).toEntity()
+	}
+	entity.Rank = rank
+	return entity
+}
+
+// SenseInfo returns the ontology information and the synonyms of a sense,
+// or nil if the sense is unknown.
+func (this *NLPEngine) SenseInfo(id string) *models.SenseEntity {
+	if this.sense == nil {
+		return nil
+	}
+	info := this.sense.semdb.getSenseInfo(id)
+	if info.words.Len() == 0 && info.semFile == "" {
+		return nil
+	}
+	return info.toEntity()
+}
+
+func (this *NLPEngine) PrintList(document *models.DocumentEntity) {
+	writer.NewWriter(writer.LEVEL_TAGGED).Write(os.Stdout, document)
+}
+
+func (this *NLPEngine) PrintTree(document *models.DocumentEntity) {
+	writer.NewWriter(writer.LEVEL_SHALLOW).Write(os.Stdout, document)
+}
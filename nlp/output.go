@@ -1,5 +1,7 @@
 package nlp
 
+import "github.com/advancedlogic/go-freeling/models"
+
 type Output struct{}
 
 func (this Output) outputSense(a *Analysis) string {
@@ -37,3 +39,26 @@ func (this Output) PrintTree(output *string, n *ParseTreeIterator, depth int) {
 		*output += CreateStringWithChar(depth*2, " ") + "]\n"
 	}
 }
+
+// treeEntity converts a parse tree into its model, referring leaves to the
+// index of their word in the sentence.
+func (this Output) treeEntity(n *ParseTreeIterator, index map[*Word]int) *models.ParseTreeEntity {
+	node := n.pnode.info.(*Node)
+	entity := &models.ParseTreeEntity{
+		Label: node.getLabel(),
+		Head:  node.isHead(),
+		Word:  -1,
+	}
+	if n.pnode.numChildren() == 0 {
+		if w := node.getWord(); w != nil {
+			if i, ok := index[w]; ok {
+				entity.Word = i
+			}
+		}
+		return entity
+	}
+	for d := n.pnode.siblingBegin(); d.pnode != n.pnode.siblingEnd().pnode; d = d.siblingPlusPlus() {
+		entity.Children = append(entity.Children, this.treeEntity(d, index))
+	}
+	return entity
+}
@@ -0,0 +1,256 @@
+package nlp
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProbabilityTrainer estimates the lexical probabilities and the unknown word
+// guesser used by Probability out of a tagged corpus and the dictionary, and
+// writes them in the probabilitats.dat format read by NewProbability.
+// Ambiguity classes are the sorted short tags the dictionary gives to a form,
+// and suffixes are collected from the rare words of the corpus, as in TnT.
+type ProbabilityTrainer struct {
+	Tags                  *TagSet
+	MaxSuffix             int
+	RareWords             int
+	BiassSuffixes         float64
+	LidstoneLambdaLexical float64
+	LidstoneLambdaClass   float64
+	dictionary            map[string]map[string]bool
+	singleTags            map[string]int
+	fullTags              map[string]int
+	formTags              map[string]map[string]int
+	formFullTags          map[string]map[string]int
+	forms                 map[string]int
+	ntags                 int
+	sentences             int
+}
+
+func NewProbabilityTrainer(tags *TagSet) *ProbabilityTrainer {
+	return &ProbabilityTrainer{
+		Tags:                  tags,
+		MaxSuffix:             10,
+		RareWords:             10,
+		BiassSuffixes:         0.3,
+		LidstoneLambdaLexical: 0.1,
+		LidstoneLambdaClass:   1.0,
+		dictionary:            make(map[string]map[string]bool),
+		singleTags:            make(map[string]int),
+		fullTags:              make(map[string]int),
+		formTags:              make(map[string]map[string]int),
+		formFullTags:          make(map[string]map[string]int),
+		forms:                 make(map[string]int),
+	}
+}
+
+// LoadDictionary reads the Entries section of a FreeLing dictionary source
+// (form lemma tag lemma tag...) to know the ambiguity class of each form.
+func (this *ProbabilityTrainer) LoadDictionary(dicFile string) bool {
+	cfg := NewConfigFile(true, "##")
+	cfg.AddSection("Entries", DICTIONARY_ENTRIES)
+	if !cfg.Open(dicFile) {
+		return false
+	}
+
+	line := ""
+	for cfg.GetContentLine(&line) {
+		if cfg.GetSection() != DICTIONARY_ENTRIES {
+			continue
+		}
+		items := strings.Fields(line)
+		if len(items) < 3 {
+			continue
+		}
+		form := strings.ToLower(items[0])
+		tags := this.dictionary[form]
+		if tags == nil {
+			tags = make(map[string]bool)
+			this.dictionary[form] = tags
+		}
+		for i := 2; i < len(items); i = i + 2 {
+			tags[this.Tags.GetShortTag(items[i])] = true
+		}
+	}
+	return true
+}
+
+// AddSentence counts the forms and full tags of a tagged sentence.
+func (this *ProbabilityTrainer) AddSentence(forms []string, tags []string) {
+	if len(forms) == 0 || len(forms) != len(tags) {
+		return
+	}
+	this.sentences++
+
+	for i, tag := range tags {
+		form := strings.Replace(strings.ToLower(forms[i]), " ", "_", -1)
+		short := this.Tags.GetShortTag(tag)
+		this.ntags++
+		this.forms[form]++
+		this.singleTags[short]++
+		this.fullTags[tag]++
+		addCount(this.formTags, form, short, 1)
+		addCount(this.formFullTags, form, tag, 1)
+	}
+}
+
+// ReadCorpus reads a tagged corpus in FreeLing or CoNLL format, as
+// HMMTrainer.ReadCorpus does.
+func (this *ProbabilityTrainer) ReadCorpus(r io.Reader, format string) error {
+	return readCorpus(r, format, this.AddSentence)
+}
+
+// Class returns the ambiguity class of a form: the sorted short tags the
+// dictionary gives to it, joined by "-", or "" if the form is unknown.
+func (this *ProbabilityTrainer) Class(form string) string {
+	tags := this.dictionary[form]
+	if len(tags) == 0 {
+		return ""
+	}
+	class := make([]string, 0, len(tags))
+	for t := range tags {
+		class = append(class, t)
+	}
+	sort.Strings(class)
+	return strings.Join(class, "-")
+}
+
+// Theeta is the standard deviation of the unconditioned tag probabilities,
+// used to weight successive suffix estimates.
+func (this *ProbabilityTrainer) Theeta() float64 {
+	if len(this.fullTags) == 0 || this.ntags == 0 {
+		return 0
+	}
+	mean := 1.0 / float64(len(this.fullTags))
+	variance := 0.0
+	for _, c := range this.fullTags {
+		p := float64(c) / float64(this.ntags)
+		variance += (p - mean) * (p - mean)
+	}
+	return math.Sqrt(variance / float64(len(this.fullTags)))
+}
+
+// Write writes the model in probabilitats.dat format. tagsetFile is written
+// in the TagsetFile section, relative to the directory of the model file.
+func (this *ProbabilityTrainer) Write(out io.Writer, tagsetFile string) error {
+	w := bufio.NewWriter(out)
+	format := func(p float64) string {
+		return strconv.FormatFloat(p, 'g', -1, 64)
+	}
+	counts := func(m map[string]int) string {
+		s := ""
+		for _, t := range sortedKeys(m) {
+			s += " " + t + " " + strconv.Itoa(m[t])
+		}
+		return s
+	}
+
+	w.WriteString("## Probability model trained on " + strconv.Itoa(this.sentences) + " sentences, " + strconv.Itoa(this.ntags) + " words\n")
+	w.WriteString("<TagsetFile>\n" + tagsetFile + "\n</TagsetFile>\n")
+
+	w.WriteString("<SingleTagFreq>\n")
+	for _, t := range sortedKeys(this.singleTags) {
+		w.WriteString(t + " " + strconv.Itoa(this.singleTags[t]) + "\n")
+	}
+	w.WriteString("</SingleTagFreq>\n")
+
+	// only ambiguous forms need lexical or class probabilities
+	classes := make(map[string]map[string]int)
+	lexical := make([]string, 0)
+	for _, form := range sortedKeys(this.forms) {
+		class := this.Class(form)
+		if !strings.Contains(class, "-") {
+			continue
+		}
+		lexical = append(lexical, form)
+		for t, c := range this.formTags[form] {
+			if this.dictionary[form][t] {
+				addCount(classes, class, t, c)
+			}
+		}
+	}
+
+	w.WriteString("<ClassTagFreq>\n")
+	for _, class := range sortedClasses(classes) {
+		w.WriteString(class + counts(classes[class]) + "\n")
+	}
+	w.WriteString("</ClassTagFreq>\n")
+
+	w.WriteString("<FormTagFreq>\n")
+	for _, form := range lexical {
+		w.WriteString(form + " " + this.Class(form) + counts(this.formTags[form]) + "\n")
+	}
+	w.WriteString("</FormTagFreq>\n")
+
+	// the guesser proposes the tags of the words seen only once, or of all
+	// the words if the corpus is too small to have any
+	unknown := make(map[string]int)
+	for form, n := range this.forms {
+		if n == 1 {
+			for t := range this.formFullTags[form] {
+				unknown[t]++
+			}
+		}
+	}
+	if len(unknown) == 0 {
+		unknown = this.fullTags
+	}
+	w.WriteString("<UnknownTags>\n")
+	for _, t := range sortedKeys(unknown) {
+		w.WriteString(t + " " + strconv.Itoa(unknown[t]) + "\n")
+	}
+	w.WriteString("</UnknownTags>\n")
+
+	w.WriteString("<Theeta>\n" + format(this.Theeta()) + "\n</Theeta>\n")
+
+	// suffixes are byte slices, as Probability looks them up
+	suffixes := make(map[string]map[string]int)
+	for form, n := range this.forms {
+		if n > this.RareWords {
+			continue
+		}
+		for l := 1; l <= this.MaxSuffix && l <= len(form); l++ {
+			suffix := form[len(form)-l:]
+			for t, c := range this.formFullTags[form] {
+				addCount(suffixes, suffix, t, c)
+			}
+		}
+	}
+	w.WriteString("<Suffixes>\n")
+	for _, suffix := range sortedClasses(suffixes) {
+		total := 0
+		for _, c := range suffixes[suffix] {
+			total += c
+		}
+		w.WriteString(suffix + " " + strconv.Itoa(total) + counts(suffixes[suffix]) + "\n")
+	}
+	w.WriteString("</Suffixes>\n")
+
+	w.WriteString("<BiassSuffixes>\n" + format(this.BiassSuffixes) + "\n</BiassSuffixes>\n")
+	w.WriteString("<LidstoneLambdaLexical>\n" + format(this.LidstoneLambdaLexical) + "\n</LidstoneLambdaLexical>\n")
+	w.WriteString("<LidstoneLambdaClass>\n" + format(this.LidstoneLambdaClass) + "\n</LidstoneLambdaClass>\n")
+
+	return w.Flush()
+}
+
+func addCount(m map[string]map[string]int, key string, value string, n int) {
+	c := m[key]
+	if c == nil {
+		c = make(map[string]int)
+		m[key] = c
+	}
+	c[value] += n
+}
+
+func sortedClasses(m map[string]map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -3,6 +3,7 @@ package nlp
 import (
 	"container/list"
 	"github.com/fatih/set"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -34,6 +35,7 @@ type Probability struct {
 	unkSuffS              map[string]map[string]float64
 	theeta                float64
 	longSuff              int
+	tagsetFile            string
 }
 
 func NewProbability(probFile string, Threashold float64) *Probability {
@@ -56,6 +58,12 @@ func NewProbability(probFile string, Threashold float64) *Probability {
 	this.LidstoneLambdaLexical = 0.1
 	this.LidstoneLambdaClass = 1.0
 
+	if this.readBinary(probFile) {
+		this.Tags = NewTagset(tagsetPath(probFile, this.tagsetFile))
+		TRACE(3, "analyzer loaded from "+BinaryFile(probFile), MOD_PROBABILITY)
+		return &this
+	}
+
 	cfg := NewConfigFile(false, "##")
 	cfg.AddSection("SingleTagFreq", PROBABILITY_SINGLE_TAG)
 	cfg.AddSection("ClassTagFreq", PROBABILITY_CLASS_TAG)
@@ -183,14 +191,66 @@ func NewProbability(probFile string, Threashold float64) *Probability {
 		this.singleTags[k] /= sumSing
 	}
 
-	path := probFile[0:strings.LastIndex(probFile, "/")]
-	this.Tags = NewTagset(path + "/" + strings.Replace(ftags, "./", "", -1))
+	this.tagsetFile = ftags
+	this.Tags = NewTagset(tagsetPath(probFile, ftags))
 
 	TRACE(3, "analyzer succesfully created", MOD_PROBABILITY)
 
 	return &this
 }
 
+// probabilityData is the compiled form of the probability model.
+type probabilityData struct {
+	SingleTags            map[string]float64
+	ClassTags             map[string]map[string]float64
+	LexicalTags           map[string]map[string]float64
+	UnkTags               map[string]float64
+	UnkSuffS              map[string]map[string]float64
+	Theeta                float64
+	LongSuff              int
+	BiassSuffixes         float64
+	LidstoneLambdaLexical float64
+	LidstoneLambdaClass   float64
+	TagsetFile            string
+}
+
+func (this *Probability) readBinary(probFile string) bool {
+	var data probabilityData
+	if !readBinary(probFile, BINARY_PROBABILITY, &data) {
+		return false
+	}
+	this.singleTags = data.SingleTags
+	this.classTags = data.ClassTags
+	this.lexicalTags = data.LexicalTags
+	this.unkTags = data.UnkTags
+	this.unkSuffS = data.UnkSuffS
+	this.theeta = data.Theeta
+	this.longSuff = data.LongSuff
+	this.BiassSuffixes = data.BiassSuffixes
+	this.LidstoneLambdaLexical = data.LidstoneLambdaLexical
+	this.LidstoneLambdaClass = data.LidstoneLambdaClass
+	this.tagsetFile = data.TagsetFile
+	return true
+}
+
+// Compile writes the compiled version of the model loaded from probFile,
+// which also depends on the tagset for its short tag classes.
+func (this *Probability) Compile(probFile string) error {
+	return writeBinary(probFile, BINARY_PROBABILITY, &probabilityData{
+		SingleTags:            this.singleTags,
+		ClassTags:             this.classTags,
+		LexicalTags:           this.lexicalTags,
+		UnkTags:               this.unkTags,
+		UnkSuffS:              this.unkSuffS,
+		Theeta:                this.theeta,
+		LongSuff:              this.longSuff,
+		BiassSuffixes:         this.BiassSuffixes,
+		LidstoneLambdaLexical: this.LidstoneLambdaLexical,
+		LidstoneLambdaClass:   this.LidstoneLambdaClass,
+		TagsetFile:            this.tagsetFile,
+	}, tagsetPath(probFile, this.tagsetFile))
+}
+
 func (this *Probability) Analyze(se *Sentence) {
 	for pos := se.Front(); pos != nil; pos = pos.Next() {
 		this.AnnotateWord(pos.Value.(*Word))
@@ -264,9 +324,16 @@ func (this *Probability) smoothing(w *Word) {
 
 		TRACE(2, "Form "+form+" lexical probabilities not found", MOD_PROBABILITY)
 		usingBackoff = true
+		// classes are written with their tags sorted
+		shorts := make([]string, 0, len(tagShorts))
+		for k, _ := range tagShorts {
+			shorts = append(shorts, k)
+		}
+		sort.Strings(shorts)
+
 		c := ""
 		cNP := ""
-		for k, _ := range tagShorts {
+		for _, k := range shorts {
 			cNP += "-" + k
 			if k != "NP" {
 				c += "-" + k
@@ -2,9 +2,12 @@ package nlp
 
 import (
 	"container/list"
-	"github.com/fatih/set"
 	"io/ioutil"
 	"strings"
+
+	"github.com/fatih/set"
+
+	"github.com/advancedlogic/go-freeling/models"
 )
 
 const (
@@ -61,6 +64,36 @@ func (this *SenseInfo) getParentsString() string {
 	return strings.Join(out, ":")
 }
 
+// toEntity converts the sense with its synonyms and ontology information.
+func (this *SenseInfo) toEntity() *models.SenseEntity {
+	entity := &models.SenseEntity{
+		Id: this.sense,
+	}
+	if this.words != nil {
+		entity.Words = StrList2StrArray(this.words)
+	}
+	if this.parents != nil {
+		entity.Parents = nonEmpty(StrList2StrArray(this.parents))
+	}
+	entity.SemFile = lexName(this.semFile)
+	if this.tonto != nil {
+		entity.Tonto = nonEmpty(StrList2StrArray(this.tonto))
+	}
+	entity.Sumo = this.sumo
+	entity.Cyc = this.cyc
+	return entity
+}
+
+func nonEmpty(items []string) []string {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
 type PosMapRule struct {
 	pos   string
 	wnpos string
@@ -199,11 +232,18 @@ func (this *SemanticDB) getWordSenses(form string, lemma string, pos string) *li
 }
 
 func (this *SemanticDB) getSenseWords(sens string) *list.List {
-	return StrArray2StrList(Split(this.senseDB.accessDatabase("S:"+sens), " "))
+	if this.senseDB == nil {
+		return list.New()
+	}
+	return StrArray2StrList(nonEmpty(Split(this.senseDB.accessDatabase("S:"+sens), " ")))
 }
 
 func (this *SemanticDB) getSenseInfo(syn string) *SenseInfo {
-	sinf := NewSenseInfo(syn, this.wndb.accessDatabase(syn))
+	data := ""
+	if this.wndb != nil {
+		data = this.wndb.accessDatabase(syn)
+	}
+	sinf := NewSenseInfo(syn, data)
 	sinf.words = this.getSenseWords(syn)
 	return sinf
 }
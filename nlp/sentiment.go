@@ -0,0 +1,261 @@
+package nlp
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/fatih/set"
+
+	"github.com/advancedlogic/go-freeling/models"
+)
+
+const (
+	SENTIMENT_NEGATORS = 1 + iota
+	SENTIMENT_INTENSIFIERS
+	SENTIMENT_PARAMS
+	SENTIMENT_LEXICON
+)
+
+const (
+	POLARITY_POSITIVE = "positive"
+	POLARITY_NEGATIVE = "negative"
+	POLARITY_NEUTRAL  = "neutral"
+)
+
+var sentimentNegators = map[string][]string{
+	"en": {"not", "no", "never", "n't", "nothing", "nobody", "none", "neither", "nor", "hardly", "without"},
+	"es": {"no", "nunca", "jamás", "nada", "nadie", "ninguno", "ni", "tampoco", "sin"},
+}
+
+var sentimentIntensifiers = map[string]map[string]float64{
+	"en": {"very": 1.5, "really": 1.5, "extremely": 2.0, "highly": 1.5, "so": 1.3, "too": 1.3, "absolutely": 1.8, "completely": 1.8, "totally": 1.8, "quite": 1.2, "rather": 1.1, "slightly": 0.5, "somewhat": 0.6, "barely": 0.4, "little": 0.6},
+	"es": {"muy": 1.5, "mucho": 1.5, "tan": 1.3, "demasiado": 1.3, "extremadamente": 2.0, "totalmente": 1.8, "completamente": 1.8, "realmente": 1.5, "bastante": 1.2, "poco": 0.6, "algo": 0.6, "apenas": 0.4},
+}
+
+// Sentiment combines the positive and negative scores of the synsets selected
+// by the Disambiguator, or of the lemmas in the Lexicon section of the
+// sentiment file, into sentence and document polarity. Negators open a
+// negation scope that flips the polarity of the following words until a
+// punctuation mark, a conjunction or NegationScope words are found, and
+// intensifiers scale the next sentiment-bearing word.
+type Sentiment struct {
+	negators          *set.Set
+	intensifiers      map[string]float64
+	lexicon           map[string][2]float64
+	NegationScope     int
+	IntensifierWindow int
+	Threshold         float64
+}
+
+func NewSentiment(lang string, sentFile string) *Sentiment {
+	this := Sentiment{
+		negators:          set.New(set.ThreadSafe).(*set.Set),
+		intensifiers:      make(map[string]float64),
+		lexicon:           make(map[string][2]float64),
+		NegationScope:     4,
+		IntensifierWindow: 2,
+		Threshold:         0.1,
+	}
+
+	if sentFile == "" {
+		for _, n := range sentimentNegators[lang] {
+			this.negators.Add(n)
+		}
+		for k, v := range sentimentIntensifiers[lang] {
+			this.intensifiers[k] = v
+		}
+		return &this
+	}
+
+	cfg := NewConfigFile(false, "##")
+	cfg.AddSection("Negators", SENTIMENT_NEGATORS)
+	cfg.AddSection("Intensifiers", SENTIMENT_INTENSIFIERS)
+	cfg.AddSection("Parameters", SENTIMENT_PARAMS)
+	cfg.AddSection("Lexicon", SENTIMENT_LEXICON)
+
+	if !cfg.Open(sentFile) {
+		LOG.Panic("Error opening file " + sentFile)
+	}
+
+	line := ""
+	for cfg.GetContentLine(&line) {
+		items := Split(line, " ")
+		switch cfg.GetSection() {
+		case SENTIMENT_NEGATORS:
+			{
+				this.negators.Add(strings.ToLower(items[0]))
+				break
+			}
+		case SENTIMENT_INTENSIFIERS:
+			{
+				if len(items) < 2 {
+					LOG.Panic("Invalid line '" + line + "' in Intensifiers section in file " + sentFile)
+				}
+				weight, err := strconv.ParseFloat(items[1], 64)
+				if err != nil {
+					LOG.Panic("Invalid intensifier weight '" + items[1] + "' in file " + sentFile)
+				}
+				this.intensifiers[strings.ToLower(items[0])] = weight
+				break
+			}
+		case SENTIMENT_PARAMS:
+			{
+				key := items[0]
+				if key == "NegationScope" {
+					this.NegationScope, _ = strconv.Atoi(items[1])
+				} else if key == "IntensifierWindow" {
+					this.IntensifierWindow, _ = strconv.Atoi(items[1])
+				} else if key == "Threshold" {
+					this.Threshold, _ = strconv.ParseFloat(items[1], 64)
+				} else {
+					LOG.Warn("Unknown parameter " + key + " in Parameters section in file " + sentFile)
+				}
+				break
+			}
+		case SENTIMENT_LEXICON:
+			{
+				if len(items) < 3 {
+					LOG.Panic("Invalid line '" + line + "' in Lexicon section in file " + sentFile)
+				}
+				pos, err1 := strconv.ParseFloat(items[1], 64)
+				neg, err2 := strconv.ParseFloat(items[2], 64)
+				if err1 != nil || err2 != nil {
+					LOG.Panic("Invalid scores for '" + items[0] + "' in Lexicon section in file " + sentFile)
+				}
+				this.lexicon[strings.ToLower(items[0])] = [2]float64{pos, neg}
+				break
+			}
+		default:
+			break
+		}
+	}
+
+	LOG.Trace("Sentiment lexicon loaded from " + sentFile)
+	return &this
+}
+
+// negator tells whether the word is a negator. Negators are matched by lemma
+// only, as they have different categories ("not", "nothing", "without") whose
+// tags differ between tagsets.
+func (this *Sentiment) negator(lemma string) bool {
+	return this.negators.Has(lemma)
+}
+
+// intensifier returns the weight of an intensifying adverb, or 0.
+func (this *Sentiment) intensifier(lemma string, tag string) float64 {
+	if !strings.HasPrefix(tag, "R") {
+		return 0
+	}
+	return this.intensifiers[lemma]
+}
+
+// scopeEnd tells whether the word closes a negation scope: punctuation
+// (EAGLES F* tags or Penn symbol tags) and coordinating or subordinating
+// conjunctions.
+func (this *Sentiment) scopeEnd(tag string) bool {
+	if tag == "" {
+		return true
+	}
+	c := tag[0]
+	if !(c >= 'A' && c <= 'Z') {
+		return true
+	}
+	return c == 'F' || strings.HasPrefix(tag, "CC") || strings.HasPrefix(tag, "CS")
+}
+
+func (this *Sentiment) Analyze(s *Sentence) *models.SentimentEntity {
+	var positive, negative float64
+	negated := 0
+	boost := 1.0
+	boostLeft := 0
+
+	for w := s.Front(); w != nil; w = w.Next() {
+		word := w.Value.(*Word)
+		if word.getNAnalysis() == 0 {
+			continue
+		}
+		lemma := strings.ToLower(word.getLemma(0))
+		tag := word.getTag(0)
+
+		// negators come first, as some of them ("nor") are conjunctions
+		if this.negator(lemma) {
+			negated = this.NegationScope
+			continue
+		}
+
+		if this.scopeEnd(tag) {
+			negated = 0
+			boostLeft = 0
+			continue
+		}
+
+		if weight := this.intensifier(lemma, tag); weight > 0 {
+			boost = weight
+			boostLeft = this.IntensifierWindow
+			continue
+		}
+
+		pos, neg := this.scores(word, lemma)
+		if pos != 0 || neg != 0 {
+			if boostLeft > 0 {
+				pos *= boost
+				neg *= boost
+				boostLeft = 0
+			}
+			if negated > 0 {
+				pos, neg = neg, pos
+			}
+			positive += pos
+			negative += neg
+		} else if boostLeft > 0 {
+			boostLeft--
+		}
+
+		if negated > 0 {
+			negated--
+		}
+	}
+
+	return this.newEntity(positive, negative)
+}
+
+// scores returns the positive and negative scores of the synset of a word,
+// or of its lemma in the lexicon when the synset has none.
+func (this *Sentiment) scores(word *Word, lemma string) (float64, float64) {
+	if syn := word.getSynset(); syn != nil && (syn.pos != 0 || syn.neg != 0) {
+		return syn.pos, syn.neg
+	}
+	scores := this.lexicon[lemma]
+	return scores[0], scores[1]
+}
+
+// Aggregate combines sentence polarities into a document polarity.
+func (this *Sentiment) Aggregate(sentiments []*models.SentimentEntity) *models.SentimentEntity {
+	var positive, negative float64
+	for _, s := range sentiments {
+		positive += s.Positive
+		negative += s.Negative
+	}
+	return this.newEntity(positive, negative)
+}
+
+func (this *Sentiment) newEntity(positive float64, negative float64) *models.SentimentEntity {
+	score := 0.0
+	if positive+negative > 0 {
+		score = (positive - negative) / (positive + negative)
+	}
+
+	polarity := POLARITY_NEUTRAL
+	if score > this.Threshold {
+		polarity = POLARITY_POSITIVE
+	} else if score < -this.Threshold {
+		polarity = POLARITY_NEGATIVE
+	}
+
+	return &models.SentimentEntity{
+		Positive: positive,
+		Negative: negative,
+		Score:    score,
+		Polarity: polarity,
+	}
+}
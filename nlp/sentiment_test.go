@@ -0,0 +1,48 @@
+package nlp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// taggedSentence builds a sentence out of lemma/tag pairs.
+func taggedSentence(words ...string) *Sentence {
+	s := NewSentence()
+	for _, word := range words {
+		parts := strings.Split(word, "/")
+		w := NewWordFromLemma(parts[0])
+		w.addAnalysis(NewAnalysis(parts[0], parts[1]))
+		s.PushBack(w)
+	}
+	return s
+}
+
+func TestSentimentNegation(t *testing.T) {
+	sentiment := NewSentiment("en", "testdata/sentiment/sentiment.dat")
+
+	tests := []struct {
+		words    []string
+		polarity string
+	}{
+		{[]string{"it/PRP", "is/VBZ", "good/JJ"}, POLARITY_POSITIVE},
+		{[]string{"it/PRP", "is/VBZ", "not/RB", "good/JJ"}, POLARITY_NEGATIVE},
+		{[]string{"neither/DT", "cheap/JJ", "nor/CC", "good/JJ"}, POLARITY_NEGATIVE},
+		{[]string{"not/RB", "cheap/JJ", ",/,", "but/CC", "good/JJ"}, POLARITY_POSITIVE},
+	}
+	for _, test := range tests {
+		if polarity := sentiment.Analyze(taggedSentence(test.words...)).Polarity; polarity != test.polarity {
+			t.Errorf("%v: got %s, expected %s", test.words, polarity, test.polarity)
+		}
+	}
+}
+
+func TestSentimentShortLexiconLine(t *testing.T) {
+	defer func() {
+		r := recover()
+		if r == nil || !strings.Contains(fmt.Sprint(r), "short.dat") {
+			t.Errorf("got %v, expected a panic naming the file", r)
+		}
+	}()
+	NewSentiment("en", "testdata/sentiment/short.dat")
+}
@@ -10,6 +10,11 @@ import (
 const SAME = 100
 const VERY_LONG = 1000
 
+// forms that start a list item at the beginning of a line
+var listBullets = map[string]bool{
+	"-": true, "*": true, "+": true, "•": true, "·": true, "–": true, "—": true,
+}
+
 const (
 	SPLITTER_GENERAL = 1 + iota
 	SPLITTER_MARKERS
@@ -20,6 +25,7 @@ const (
 type Splitter struct {
 	SPLIT_AllowBetweenMarkers bool
 	SPLIT_MaxWords            int64
+	SPLIT_LineBreaks          bool
 	starters                  *set.Set
 	enders                    map[string]bool
 	markers                   map[string]int
@@ -58,6 +64,8 @@ func NewSplitter(splitterFile string) *Splitter {
 					this.SPLIT_AllowBetweenMarkers, _ = strconv.ParseBool(items[1])
 				} else if name == "MaxWords" {
 					this.SPLIT_MaxWords, _ = strconv.ParseInt(items[1], 10, 64)
+				} else if name == "LineBreaks" {
+					this.SPLIT_LineBreaks, _ = strconv.ParseBool(items[1])
 				} else {
 					LOG.Panic("Unexpected splitter option " + name)
 				}
@@ -105,6 +113,7 @@ type SplitterStatus struct {
 	MarkForm     *list.List
 	buffer       *Sentence
 	nsentence    int
+	paragraph    int
 }
 
 func (this *Splitter) OpenSession() *SplitterStatus {
@@ -126,10 +135,48 @@ func (this *Splitter) CloseSession(ses *SplitterStatus) {
 	ses = nil
 }
 
+// reset starts a new sentence in the session, keeping the sentence count.
+// The session is modified in place, as the caller keeps using it.
+func (this *SplitterStatus) reset() {
+	this.BetweenMark = false
+	this.NoSplitCount = 0
+	this.MarkType.Init()
+	this.MarkForm.Init()
+	this.buffer = NewSentence()
+}
+
+// endSentence adds the words in the session buffer as a sentence to ls.
+func (this *Splitter) endSentence(st *SplitterStatus, ls *list.List) {
+	st.nsentence++
+	st.buffer.sentID = strconv.Itoa(st.nsentence)
+	st.buffer.setParagraphID(st.paragraph)
+	ls.PushBack(st.buffer)
+	LOG.Trace("Sentence lenght " + strconv.Itoa(st.buffer.Len()))
+	st.reset()
+}
+
+// Split groups the words in v into sentences, added to ls. Besides sentence
+// markers, a blank line starts a new paragraph and always ends the sentence,
+// as does a line break before a list item (or any line break with the
+// LineBreaks option). Sentences are cut at MaxWords words when it is set.
+// Unless flush is set, the words of an unfinished sentence are kept in the
+// session for the next call.
 func (this *Splitter) Split(st *SplitterStatus, v *list.List, flush bool, ls *list.List) {
 	ls = ls.Init()
 	LOG.Trace("Looking for a sentence marker. Max no split is " + strconv.Itoa(int(this.SPLIT_MaxWords)))
 	for w := v.Front(); w != nil; w = w.Next() {
+		breaks := w.Value.(*Word).getLineBreaks()
+		if st.paragraph == 0 || breaks > 1 {
+			if st.buffer.Len() > 0 {
+				LOG.Trace("Paragraph break before " + w.Value.(*Word).getForm())
+				this.endSentence(st, ls)
+			}
+			st.paragraph++
+		} else if breaks == 1 && st.buffer.Len() > 0 && (this.SPLIT_LineBreaks || this.listItem(w)) {
+			LOG.Trace("Line break before " + w.Value.(*Word).getForm())
+			this.endSentence(st, ls)
+		}
+
 		m := this.markers[w.Value.(*Word).getForm()]
 		checkSplit := true
 
@@ -168,19 +215,12 @@ func (this *Splitter) Split(st *SplitterStatus, v *list.List, flush bool, ls *li
 		}
 
 		if checkSplit {
-			e := this.enders[w.Value.(*Word).getForm()]
+			e := this.enders[w.Value.(*Word).getForm()] && !st.listNumber()
 			if e {
 				if e || this.endOfSentence(w, v) {
 					LOG.Trace("Sentence marker [" + w.Value.(*Word).getForm() + "] found")
 					st.buffer.PushBack(w.Value.(*Word))
-					st.nsentence++
-					st.buffer.sentID = strconv.Itoa(st.nsentence)
-					ls.PushBack(st.buffer)
-					LOG.Trace("Sentence lenght " + strconv.Itoa(st.buffer.Len()))
-					nsentence := st.nsentence
-					this.CloseSession(st)
-					st = this.OpenSession()
-					st.nsentence = nsentence
+					this.endSentence(st, ls)
 				} else {
 					LOG.Trace(w.Value.(*Word).getForm() + " is not a sentence marker here")
 					st.buffer.PushBack(w.Value.(*Word))
@@ -190,18 +230,50 @@ func (this *Splitter) Split(st *SplitterStatus, v *list.List, flush bool, ls *li
 				st.buffer.PushBack(w.Value.(*Word))
 			}
 		}
+
+		if this.SPLIT_MaxWords > 0 && st.buffer.Len() >= int(this.SPLIT_MaxWords) {
+			LOG.Trace("Sentence reached " + strconv.Itoa(int(this.SPLIT_MaxWords)) + " words, splitting")
+			this.endSentence(st, ls)
+		}
 	}
 
 	if flush && st.buffer.Len() > 0 {
 		LOG.Trace("Flushing the remaining words into a sentence")
-		st.nsentence++
-		st.buffer.sentID = strconv.Itoa(st.nsentence)
-		ls.PushBack(st.buffer)
-		nsentence := st.nsentence
-		this.CloseSession(st)
-		st = this.OpenSession()
-		st.nsentence = nsentence
+		this.endSentence(st, ls)
+	}
+}
+
+// listNumber tells whether the sentence so far is just a number at the
+// start of a line, so the period after it marks a list item (1.) and not
+// the end of the sentence.
+func (this *SplitterStatus) listNumber() bool {
+	if this.buffer.Len() != 1 {
+		return false
+	}
+	first := this.buffer.Front().Value.(*Word)
+	if first.getLineBreaks() == 0 && this.nsentence > 0 {
+		return false
+	}
+	_, err := strconv.Atoi(first.getForm())
+	return err == nil
+}
+
+// listItem tells whether a word starts a list item: a bullet, or a number
+// followed by a period or a parenthesis.
+func (this *Splitter) listItem(w *list.Element) bool {
+	form := w.Value.(*Word).getForm()
+	if listBullets[form] {
+		return true
+	}
+	if number := strings.TrimRight(form, ".)"); number != form {
+		_, err := strconv.Atoi(number)
+		return err == nil
+	}
+	if _, err := strconv.Atoi(form); err != nil || w.Next() == nil {
+		return false
 	}
+	next := w.Next().Value.(*Word).getForm()
+	return next == "." || next == ")"
 }
 
 func (this *Splitter) endOfSentence(w *list.Element, v *list.List) bool {
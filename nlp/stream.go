@@ -0,0 +1,212 @@
+package nlp
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/advancedlogic/go-freeling/models"
+)
+
+const (
+	// bytes read from the stream at once
+	STREAM_CHUNK = 64 * 1024
+	// longest run of text without blanks kept before cutting it
+	STREAM_MAX_PENDING = 1024 * 1024
+)
+
+// ErrNoStream is returned when the pipeline can not split a text in
+// sentences.
+var ErrNoStream = errors.New("stream analysis needs a tokenizer and a splitter")
+
+// TextStream analyzes a text given in pieces, carrying the splitter session
+// from a piece to the next, so sentences may span pieces. Every sentence is
+// sent as soon as the splitter closes it. Document level steps that need the
+// whole text (domains, MITIE entities) are skipped. A TextStream is used by
+// one goroutine at a time.
+type TextStream struct {
+	engine *NLPEngine
+	status *SplitterStatus
+	// pending holds the text from offset on that belongs to sentences not
+	// sent yet, and tokenized is the part of it already tokenized
+	pending   string
+	offset    int
+	tokenized int
+	tokens    *list.List
+	sentences *list.List
+}
+
+// NewTextStream opens a stream analysis session, to be closed with Close.
+func (this *NLPEngine) NewTextStream() (*TextStream, error) {
+	if this.tokenizer == nil || this.splitter == nil {
+		return nil, ErrNoStream
+	}
+	return &TextStream{
+		engine:    this,
+		status:    this.splitter.OpenSession(),
+		tokens:    list.New(),
+		sentences: list.New(),
+	}, nil
+}
+
+// Write adds a piece of text to the stream. The text is tokenized up to its
+// last blank, as a word may go on in the next piece, and the sentences it
+// completes are passed to send. It returns false if send does.
+func (this *TextStream) Write(text string, send func(*models.SentenceEntity) bool) bool {
+	this.pending += text
+	return this.tokenize(streamCut(this.pending, this.tokenized), send)
+}
+
+// Flush analyzes the rest of the text, ending its last sentence even without
+// a sentence marker, and passes the sentences to send. The stream can go on
+// afterwards. It returns false if send does.
+func (this *TextStream) Flush(send func(*models.SentenceEntity) bool) bool {
+	if !this.tokenize(len(this.pending), send) {
+		return false
+	}
+	this.tokens.Init()
+	return this.split(true, send)
+}
+
+// Close ends the splitter session of the stream.
+func (this *TextStream) Close() {
+	this.engine.splitter.CloseSession(this.status)
+}
+
+// tokenize tokenizes the pending text up to cut and splits it.
+func (this *TextStream) tokenize(cut int, send func(*models.SentenceEntity) bool) bool {
+	if cut <= this.tokenized {
+		return true
+	}
+	// the blanks at the end stay pending, as the line breaks before a word
+	// are counted when tokenizing it
+	chunk := strings.TrimRightFunc(this.pending[this.tokenized:cut], isBlank)
+	if strings.TrimLeftFunc(chunk, isBlank) == "" {
+		return true
+	}
+	this.engine.tokenizer.Tokenize(chunk, this.offset+this.tokenized, this.tokens)
+	this.tokenized += len(chunk)
+	return this.split(false, send)
+}
+
+// split sends the sentences closed by the splitter, and drops their text.
+func (this *TextStream) split(flush bool, send func(*models.SentenceEntity) bool) bool {
+	this.engine.splitter.Split(this.status, this.tokens, flush, this.sentences)
+	if this.sentences.Len() > 0 && !this.engine.analyzeStreamSentences(this.sentences, this.pending, this.offset, send) {
+		return false
+	}
+	// drop the text of the sentences already sent
+	keep := this.tokenized
+	if this.status.buffer.Len() > 0 {
+		keep = this.status.buffer.Front().Value.(*Word).getSpanStart() - this.offset
+	}
+	if keep > 0 {
+		this.pending = this.pending[keep:]
+		this.offset += keep
+		this.tokenized -= keep
+	}
+	return true
+}
+
+// AnalyzeStream analyzes a text read from r and sends every sentence as soon
+// as it is complete, so memory use depends on the sentence length and not
+// on the text length. The text is read in chunks given to a TextStream. The
+// channel is closed at the end of the text, when reading it fails or once
+// done is closed by a consumer that stops reading the sentences; a nil done
+// is never closed. A Read in progress is not interrupted.
+func (this *NLPEngine) AnalyzeStream(r io.Reader, done <-chan struct{}) <-chan *models.SentenceEntity {
+	output := make(chan *models.SentenceEntity)
+	go func() {
+		defer close(output)
+		defer func() {
+			if e := recover(); e != nil {
+				LOG.Errorf("Stream analysis failed: %v", e)
+			}
+		}()
+		stream, err := this.NewTextStream()
+		if err != nil {
+			LOG.Error(err.Error())
+			return
+		}
+		defer stream.Close()
+
+		send := func(se *models.SentenceEntity) bool {
+			select {
+			case output <- se:
+				return true
+			case <-done:
+				return false
+			}
+		}
+
+		buffer := make([]byte, STREAM_CHUNK)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			n, err := r.Read(buffer)
+			if !stream.Write(string(buffer[:n]), send) {
+				return
+			}
+
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				LOG.Error("Error reading the stream: " + err.Error())
+				break
+			}
+		}
+		stream.Flush(send)
+	}()
+	return output
+}
+
+// analyzeStreamSentences analyzes a group of complete sentences of a stream
+// and sends them in order. text holds the stream from offset on. It returns
+// false if send does before every sentence is sent.
+func (this *NLPEngine) analyzeStreamSentences(sentences *list.List, text string, offset int, send func(*models.SentenceEntity) bool) bool {
+	this.analyzeSentences(sentences)
+
+	if this.dsb != nil {
+		this.dsb.Analyze(sentences)
+	}
+
+	if this.disambiguator != nil {
+		this.disambiguator.Analyze(sentences)
+	}
+
+	for ss := sentences.Front(); ss != nil; ss = ss.Next() {
+		if !send(this.sentenceEntity(ss.Value.(*Sentence), text, offset, false, nil)) {
+			return false
+		}
+	}
+	return true
+}
+
+// streamCut returns where the text read so far can be cut for tokenizing,
+// after the last blank following the already tokenized part. Without blanks,
+// the text is cut at a character boundary once it is too long.
+func streamCut(text string, tokenized int) int {
+	if i := strings.LastIndexFunc(text[tokenized:], isBlank); i >= 0 {
+		return tokenized + i + 1
+	}
+	if len(text)-tokenized < STREAM_MAX_PENDING {
+		return tokenized
+	}
+	cut := len(text)
+	for cut > tokenized && !utf8.RuneStart(text[cut-1]) {
+		cut--
+	}
+	if cut > tokenized {
+		cut--
+	}
+	return cut
+}
+
+func isBlank(r rune) bool {
+	return r < utf8.RuneSelf && WhiteSpace(uint8(r))
+}
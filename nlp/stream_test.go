@@ -0,0 +1,44 @@
+package nlp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/advancedlogic/go-freeling/models"
+	"github.com/advancedlogic/go-freeling/wordnet"
+)
+
+// TestTextStream writes a sentence across two pieces of text and checks that
+// it is sent once it is closed, and that Flush ends an unfinished one.
+func TestTextStream(t *testing.T) {
+	engine := newTestEngine(1)
+	engine.WordNet = new(wordnet.WN)
+	stream, err := engine.NewTextStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	sent := make([]string, 0)
+	send := func(se *models.SentenceEntity) bool {
+		words := make([]string, 0)
+		for tt := se.Tokens().Front(); tt != nil; tt = tt.Next() {
+			words = append(words, tt.Value.(*models.TokenEntity).ToJSON().(map[string]interface{})["base"].(string))
+		}
+		sent = append(sent, strings.Join(words, " "))
+		return true
+	}
+
+	stream.Write("The cat\n", send)
+	if len(sent) != 0 {
+		t.Fatalf("unfinished sentence sent: %q", sent)
+	}
+	stream.Write("sits. The dog\n", send)
+	if len(sent) != 1 || sent[0] != "The cat sits ." {
+		t.Fatalf("got %q, expected the sentence across both pieces", sent)
+	}
+	stream.Flush(send)
+	if len(sent) != 2 || sent[1] != "The dog" {
+		t.Fatalf("got %q, expected Flush to end the last sentence", sent)
+	}
+}
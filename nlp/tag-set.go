@@ -22,6 +22,12 @@ const (
 	DECOMPOSITION_RULES
 )
 
+// tagsetPath returns the path of a tagset file given in a data file, where it
+// is relative to the directory of the data file.
+func tagsetPath(dataFile string, ftagset string) string {
+	return dataFile[0:strings.LastIndex(dataFile, "/")] + "/" + strings.Replace(ftagset, "./", "", -1)
+}
+
 func NewTagset(ftagset string) *TagSet {
 	this := &TagSet{
 		PAIR_SEP:  "=",
@@ -78,17 +84,22 @@ func NewTagset(ftagset string) *TagSet {
 				}
 				//TRACE(3, fmt.Sprintf("Read short tag size for %s (%s) %s\n", cat, pos, shsz), MOD_TAG_SET)
 				i := 1
-				if len(items) > 4 {
-					msd := items[4]
+				for j := 3; j < len(items); j++ {
+					msd := items[j]
 					key := cat + "#" + strconv.Itoa(i)
 					k := strings.Split(msd, "/")
 					this.feat[key] = k[0]
 					this.feat[cat+"#"+k[0]] = strconv.Itoa(i)
-					v := strings.Split(k[1], ";")
-					for j := 0; j < len(v); j++ {
-						t := strings.Split(v[j], ":")
-						this.val[key+"#"+strings.ToUpper(t[0])] = t[1]
-						this.valInv[key+"#"+t[1]] = strings.ToUpper(t[0])
+					if len(k) > 1 {
+						v := strings.Split(k[1], ";")
+						for _, tv := range v {
+							t := strings.Split(tv, ":")
+							if len(t) < 2 {
+								continue
+							}
+							this.val[key+"#"+strings.ToUpper(t[0])] = t[1]
+							this.valInv[key+"#"+t[1]] = strings.ToUpper(t[0])
+						}
 					}
 
 					i++
@@ -140,3 +151,58 @@ func (this TagSet) GetShortTag(tag string) string {
 	WARNING("No rule to get short version of tag '"+tag+"'.", MOD_TAG_SET)
 	return tag
 }
+
+// GetMSDFeatures decomposes a tag into a feature map (e.g. pos=noun,
+// type=common, num=singular), using the msd of DirectTranslations or the
+// positional DecompositionRules.
+func (this TagSet) GetMSDFeatures(tag string) map[string]string {
+	features := make(map[string]string)
+	if tag == "" {
+		return features
+	}
+
+	p := this.direct[tag]
+	if p != nil {
+		for _, fv := range strings.Split(p.second.(string), this.MSD_SEP) {
+			kv := strings.SplitN(fv, this.PAIR_SEP, 2)
+			if len(kv) == 2 && kv[0] != "" {
+				features[kv[0]] = kv[1]
+			}
+		}
+		return features
+	}
+
+	cat := tag[0:1]
+	pos, ok := this.name[cat]
+	if !ok {
+		return features
+	}
+	features["pos"] = pos
+	for i := 1; i < len(tag); i++ {
+		key := cat + "#" + strconv.Itoa(i)
+		name := this.feat[key]
+		if name == "" || tag[i] == '0' {
+			continue
+		}
+		if value := this.val[key+"#"+strings.ToUpper(tag[i:i+1])]; value != "" {
+			features[name] = value
+		}
+	}
+	return features
+}
+
+// GetWordNetPOS maps a tag to a WordNet part of speech (n, v, a, r), or ""
+// for categories not covered by WordNet.
+func (this TagSet) GetWordNetPOS(tag string) string {
+	switch this.GetMSDFeatures(tag)["pos"] {
+	case "noun":
+		return "n"
+	case "verb":
+		return "v"
+	case "adjective":
+		return "a"
+	case "adverb":
+		return "r"
+	}
+	return ""
+}
@@ -80,6 +80,7 @@ func NewTokenizer(tokenizerFile string) *Tokenizer {
 					newre := "(?i)" + re
 					x, err := regexp.Compile(newre)
 					if err == nil {
+						x.Longest()
 						this.rules.PushBack(Pair{comm, x})
 					} else {
 						LOG.Warn("Rule " + comm + " [" + newre + "] failed to be compiled")
@@ -87,6 +88,7 @@ func NewTokenizer(tokenizerFile string) *Tokenizer {
 				} else {
 					x, err := regexp.Compile(re)
 					if err == nil {
+						x.Longest()
 						this.rules.PushBack(Pair{comm, x})
 					} else {
 						LOG.Warn("Rule " + comm + " [" + re + "] failed to be compiled")
@@ -122,11 +124,18 @@ func (this *Tokenizer) Tokenize(p string, offset int, v *list.List) {
 	v = v.Init()
 
 	cont := 0
+	breaks := 0
 	for cont < len(p) {
-		for WhiteSpace(p[cont]) {
+		for cont < len(p) && WhiteSpace(p[cont]) {
+			if p[cont] == '\n' {
+				breaks++
+			}
 			cont++
 			offset++
 		}
+		if cont == len(p) {
+			break
+		}
 		LOG.Trace("Tokenizing [" + p[cont:] + "]")
 		match = false
 
@@ -170,6 +179,8 @@ func (this *Tokenizer) Tokenize(p string, offset int, v *list.List) {
 					LOG.Trace("Accepting matched substring [" + t[j] + "]")
 					w := NewWordFromLemma(t[j])
 					w.setSpan(offset, offset+len(t[j]))
+					w.setLineBreaks(breaks)
+					breaks = 0
 					offset += len(t[j])
 					v.PushBack(w)
 				} else {
@@ -180,6 +191,7 @@ func (this *Tokenizer) Tokenize(p string, offset int, v *list.List) {
 		} else if cont < len(p) {
 			LOG.Warn("No rule matched input substring" + p[cont:] + " . Character " + string(p[cont:][0]) + " skipped . Check your tokenization rules")
 			cont++
+			offset++
 		}
 	}
 
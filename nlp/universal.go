@@ -0,0 +1,155 @@
+package nlp
+
+import (
+	"sort"
+	"strings"
+)
+
+// Universal Dependencies part of speech for the FreeLing category names
+// found in the pos feature of the tagsets.
+var universalPOS = map[string]string{
+	"adjective":    "ADJ",
+	"adverb":       "ADV",
+	"determiner":   "DET",
+	"noun":         "NOUN",
+	"verb":         "VERB",
+	"pronoun":      "PRON",
+	"interjection": "INTJ",
+	"preposition":  "ADP",
+	"adposition":   "ADP",
+	"punctuation":  "PUNCT",
+	"number":       "NUM",
+	"date":         "NUM",
+	"particle":     "PART",
+	"symbol":       "SYM",
+}
+
+// Universal Dependencies features for FreeLing feature name and value pairs.
+var universalFeats = map[string]map[string]string{
+	"num": {
+		"singular":   "Number=Sing",
+		"plural":     "Number=Plur",
+		"invariable": "",
+	},
+	"gen": {
+		"masculine": "Gender=Masc",
+		"feminine":  "Gender=Fem",
+		"neuter":    "Gender=Neut",
+		"common":    "Gender=Com",
+	},
+	"person": {
+		"1": "Person=1",
+		"2": "Person=2",
+		"3": "Person=3",
+	},
+	"tense": {
+		"present":     "Tense=Pres",
+		"past":        "Tense=Past",
+		"imperfect":   "Tense=Imp",
+		"future":      "Tense=Fut",
+		"conditional": "Mood=Cnd",
+	},
+	"mood": {
+		"indicative":     "Mood=Ind",
+		"subjunctive":    "Mood=Sub",
+		"imperative":     "Mood=Imp",
+		"infinitive":     "VerbForm=Inf",
+		"gerund":         "VerbForm=Ger",
+		"participle":     "VerbForm=Part",
+		"pastparticiple": "VerbForm=Part",
+	},
+	"vform": {
+		"infinitive":     "VerbForm=Inf",
+		"gerund":         "VerbForm=Ger",
+		"participle":     "VerbForm=Part",
+		"pastparticiple": "VerbForm=Part",
+		"past":           "Tense=Past",
+		"present":        "Tense=Pres",
+		"personal":       "VerbForm=Fin",
+	},
+	"degree": {
+		"comparative":  "Degree=Cmp",
+		"superlative":  "Degree=Sup",
+		"augmentative": "Degree=Aug",
+		"diminutive":   "Degree=Dim",
+	},
+	"case": {
+		"nominative": "Case=Nom",
+		"accusative": "Case=Acc",
+		"dative":     "Case=Dat",
+		"genitive":   "Case=Gen",
+	},
+	"type": {
+		"personal":      "PronType=Prs",
+		"demonstrative": "PronType=Dem",
+		"interrogative": "PronType=Int",
+		"relative":      "PronType=Rel",
+		"indefinite":    "PronType=Ind",
+		"exclamative":   "PronType=Exc",
+		"article":       "PronType=Art",
+		"possessive":    "Poss=Yes",
+		"ordinal":       "NumType=Ord",
+		"cardinal":      "NumType=Card",
+	},
+	"polite": {
+		"polite": "Polite=Form",
+	},
+}
+
+// universalFeatsOrder is the order in which FreeLing features are mapped. When
+// two of them give the same Universal Dependencies feature the first one
+// wins: vform before mood for VerbForm, and tense before mood so that a
+// conditional is Mood=Cnd even when the tag also says indicative.
+var universalFeatsOrder = []string{"vform", "tense", "mood", "num", "gen", "person", "degree", "case", "type", "polite"}
+
+// UniversalPOS maps a decomposed tag to its Universal Dependencies part of
+// speech, taking the type feature into account for proper nouns, auxiliary
+// verbs and conjunctions.
+func UniversalPOS(features map[string]string) string {
+	pos := features["pos"]
+	kind := features["type"]
+	switch pos {
+	case "noun":
+		if kind == "proper" {
+			return "PROPN"
+		}
+	case "verb":
+		if kind == "auxiliary" || kind == "semiauxiliary" || kind == "modal" {
+			return "AUX"
+		}
+	case "conjunction":
+		if kind == "subordinating" {
+			return "SCONJ"
+		}
+		return "CCONJ"
+	}
+
+	if upos, ok := universalPOS[pos]; ok {
+		return upos
+	}
+	return "X"
+}
+
+// UniversalFeats maps a decomposed tag to the Universal Dependencies feature
+// string (e.g. Gender=Fem|Number=Sing), sorted by feature name as CoNLL-U
+// requires. It returns an empty string when no feature applies.
+func UniversalFeats(features map[string]string) string {
+	feats := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, name := range universalFeatsOrder {
+		ufeat := universalFeats[name][features[name]]
+		if ufeat == "" {
+			continue
+		}
+		key := ufeat[0:strings.Index(ufeat, "=")]
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		feats = append(feats, ufeat)
+	}
+	sort.Slice(feats, func(i, j int) bool {
+		return strings.ToLower(feats[i]) < strings.ToLower(feats[j])
+	})
+	return strings.Join(feats, "|")
+}
@@ -0,0 +1,219 @@
+package nlp
+
+import (
+	"bufio"
+	"container/list"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/advancedlogic/go-freeling/models"
+)
+
+var ErrInvalidEntry = errors.New("dictionary entries need a form, a lemma and a tag without blanks")
+var ErrNoDictionary = errors.New("no dictionary loaded")
+
+// LoadUserDictionary merges the entries of a user dictionary over the base
+// one. The file holds dictionary entry lines (form lemma tag lemma tag...),
+// and is where entries added at runtime are saved. A missing file is an
+// empty user dictionary.
+func (this *Dictionary) LoadUserDictionary(userFile string) error {
+	this.userMutex.Lock()
+	defer this.userMutex.Unlock()
+
+	this.userFile = userFile
+	this.userEntries = make(map[string][]*models.LexiconEntry)
+
+	file, err := os.Open(userFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "##") || strings.HasPrefix(line, "<") {
+			continue
+		}
+		items := strings.Fields(line)
+		for i := 1; i+1 < len(items); i = i + 2 {
+			this.addUserEntry(items[0], items[i], items[i+1])
+		}
+	}
+	LOG.Tracef("Loaded %d user dictionary forms from %s", len(this.userEntries), userFile)
+	return scanner.Err()
+}
+
+// Lookup returns the base and user entries of a form.
+func (this *Dictionary) Lookup(form string) []*models.LexiconEntry {
+	key := strings.ToLower(form)
+	la := list.New()
+	this.SearchForm(key, la)
+
+	user := make(map[string]bool)
+	for _, entry := range this.userAnalyses(key) {
+		user[entry.Lemma+" "+entry.Tag] = true
+	}
+
+	entries := make([]*models.LexiconEntry, 0, la.Len())
+	for a := la.Front(); a != nil; a = a.Next() {
+		analysis := a.Value.(*Analysis)
+		entries = append(entries, &models.LexiconEntry{
+			Form:  key,
+			Lemma: analysis.getLemma(),
+			Tag:   analysis.getTag(),
+			User:  user[analysis.getLemma()+" "+analysis.getTag()],
+		})
+	}
+	return entries
+}
+
+// UserEntries returns the entries added to the base dictionary, sorted by
+// form.
+func (this *Dictionary) UserEntries() []*models.LexiconEntry {
+	this.userMutex.RLock()
+	defer this.userMutex.RUnlock()
+
+	forms := make([]string, 0, len(this.userEntries))
+	for form := range this.userEntries {
+		forms = append(forms, form)
+	}
+	sort.Strings(forms)
+
+	entries := make([]*models.LexiconEntry, 0, len(forms))
+	for _, form := range forms {
+		entries = append(entries, this.userEntries[form]...)
+	}
+	return entries
+}
+
+// AddEntry adds a user entry and saves the user dictionary. It returns false
+// if the form already had that lemma and tag.
+func (this *Dictionary) AddEntry(form string, lemma string, tag string) (bool, error) {
+	if !validEntry(form, lemma, tag) {
+		return false, ErrInvalidEntry
+	}
+	key := strings.ToLower(form)
+	for _, entry := range this.Lookup(key) {
+		if entry.Lemma == lemma && entry.Tag == tag {
+			return false, nil
+		}
+	}
+
+	this.userMutex.Lock()
+	defer this.userMutex.Unlock()
+	this.addUserEntry(key, lemma, tag)
+	if err := this.saveUserDictionary(); err != nil {
+		this.removeUserEntries(key, lemma, tag)
+		return false, err
+	}
+	return true, nil
+}
+
+// RemoveEntry removes the user entries of a form with the given lemma and
+// tag, or with any of them when empty, and saves the user dictionary. Base
+// entries can not be removed. It returns the number of removed entries.
+func (this *Dictionary) RemoveEntry(form string, lemma string, tag string) (int, error) {
+	key := strings.ToLower(form)
+
+	this.userMutex.Lock()
+	defer this.userMutex.Unlock()
+	previous := this.userEntries[key]
+	removed := this.removeUserEntries(key, lemma, tag)
+	if removed == 0 {
+		return 0, nil
+	}
+	if err := this.saveUserDictionary(); err != nil {
+		this.userEntries[key] = previous
+		return 0, err
+	}
+	return removed, nil
+}
+
+func (this *Dictionary) userAnalyses(key string) []*models.LexiconEntry {
+	this.userMutex.RLock()
+	defer this.userMutex.RUnlock()
+	return this.userEntries[key]
+}
+
+func (this *Dictionary) addUserEntry(form string, lemma string, tag string) {
+	key := strings.ToLower(form)
+	for _, entry := range this.userEntries[key] {
+		if entry.Lemma == lemma && entry.Tag == tag {
+			return
+		}
+	}
+	this.userEntries[key] = append(this.userEntries[key], &models.LexiconEntry{
+		Form:  key,
+		Lemma: lemma,
+		Tag:   tag,
+		User:  true,
+	})
+}
+
+func (this *Dictionary) removeUserEntries(key string, lemma string, tag string) int {
+	kept := make([]*models.LexiconEntry, 0)
+	for _, entry := range this.userEntries[key] {
+		if (lemma == "" || entry.Lemma == lemma) && (tag == "" || entry.Tag == tag) {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	removed := len(this.userEntries[key]) - len(kept)
+	if len(kept) == 0 {
+		delete(this.userEntries, key)
+	} else {
+		this.userEntries[key] = kept
+	}
+	return removed
+}
+
+// saveUserDictionary writes the user entries, one form per line, replacing
+// the file once it is completely written.
+func (this *Dictionary) saveUserDictionary() error {
+	if this.userFile == "" {
+		return nil
+	}
+
+	forms := make([]string, 0, len(this.userEntries))
+	for form := range this.userEntries {
+		forms = append(forms, form)
+	}
+	sort.Strings(forms)
+
+	tmp := this.userFile + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	w.WriteString("## User dictionary: form lemma tag [lemma tag...]\n")
+	for _, form := range forms {
+		w.WriteString(form)
+		for _, entry := range this.userEntries[form] {
+			w.WriteString(" " + entry.Lemma + " " + entry.Tag)
+		}
+		w.WriteString("\n")
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, this.userFile)
+}
+
+func validEntry(fields ...string) bool {
+	for _, field := range fields {
+		if field == "" || strings.ContainsAny(field, " \t\n") {
+			return false
+		}
+	}
+	return true
+}
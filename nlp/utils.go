@@ -37,11 +37,13 @@ func WhiteSpace(c uint8) bool {
 	return false
 }
 
+// RegExHasSuffix returns the submatches of the first match of re in s. The
+// rules are made leftmost-longest once when loaded, as re is shared by
+// concurrent calls.
 func RegExHasSuffix(re *regexp.Regexp, s string) []string {
 	if s == "" {
 		return make([]string, 0)
 	}
-	re.Longest()
 	outs := re.FindAllStringSubmatch(s, -1)
 	newOuts := make([]string, 0)
 	if len(outs) > 0 {
@@ -2,9 +2,20 @@ package terminal
 
 import (
 	"github.com/fatih/color"
+	"io"
 	"strings"
 )
 
+// SetOutput redirects terminal messages, e.g. to os.Stderr when the
+// analysis results are written to the standard output.
+func SetOutput(w io.Writer) {
+	color.Output = w
+}
+
+func Writer() io.Writer {
+	return color.Output
+}
+
 var white = color.New(color.FgWhite)
 
 func Default(messages ...string) {
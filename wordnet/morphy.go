@@ -0,0 +1,89 @@
+package wordnet
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// Detachment rules of the WordNet morphy algorithm: inflectional suffix and
+// its replacement, per part of speech.
+var detachmentRules = map[string][][2]string{
+	"n": {{"s", ""}, {"ses", "s"}, {"xes", "x"}, {"zes", "z"}, {"ches", "ch"}, {"shes", "sh"}, {"men", "man"}, {"ies", "y"}},
+	"v": {{"s", ""}, {"ies", "y"}, {"es", "e"}, {"es", ""}, {"ed", "e"}, {"ed", ""}, {"ing", "e"}, {"ing", ""}},
+	"a": {{"er", ""}, {"est", ""}, {"er", "e"}, {"est", "e"}},
+	"r": {},
+}
+
+var exceptionFiles = map[string]string{
+	"n": "noun.exc",
+	"v": "verb.exc",
+	"a": "adj.exc",
+	"r": "adv.exc",
+}
+
+// loadExceptions reads the morphy exception lists (inflected form followed
+// by its base forms) shipped with the WordNet database.
+func loadExceptions(path string) map[string]map[string][]string {
+	exceptions := make(map[string]map[string][]string)
+	for pos, name := range exceptionFiles {
+		exceptions[pos] = make(map[string][]string)
+		file, err := os.Open(path + "/" + name)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			items := strings.Fields(scanner.Text())
+			if len(items) < 2 {
+				continue
+			}
+			exceptions[pos][items[0]] = items[1:]
+		}
+		file.Close()
+	}
+	return exceptions
+}
+
+func (this *WN) exists(lemma string, pos string) bool {
+	for p, synsets := range this.wn.Search(lemma) {
+		if shortPOS(p) == pos && len(synsets) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Morphy returns the base forms of word found in WordNet for the given part
+// of speech, looking first at the exception lists and then applying the
+// detachment rules.
+func (this *WN) Morphy(word string, pos string) []string {
+	if this.wn == nil {
+		return nil
+	}
+	word = strings.Replace(strings.ToLower(word), " ", "_", -1)
+
+	result := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(base string) {
+		if base != "" && !seen[base] && this.exists(base, pos) {
+			seen[base] = true
+			result = append(result, base)
+		}
+	}
+
+	for _, base := range this.exceptions[pos][word] {
+		add(base)
+	}
+	add(word)
+	if len(result) > 0 {
+		return result
+	}
+
+	for _, rule := range detachmentRules[pos] {
+		if strings.HasSuffix(word, rule[0]) {
+			add(strings.TrimSuffix(word, rule[0]) + rule[1])
+		}
+	}
+	return result
+}
@@ -0,0 +1,248 @@
+package wordnet
+
+import (
+	"errors"
+	"math"
+
+	. "github.com/fluhus/gostuff/nlp/wordnet"
+)
+
+const (
+	REL_HYPERNYM = "hypernym"
+	REL_HYPONYM  = "hyponym"
+	REL_MERONYM  = "meronym"
+	REL_HOLONYM  = "holonym"
+	REL_ANTONYM  = "antonym"
+)
+
+const (
+	SIM_PATH = "path"
+	SIM_WUP  = "wup"
+	SIM_LCH  = "lch"
+)
+
+// WordNet pointer symbols for every relation, see wninput(5WN).
+var relationSymbols = map[string][]string{
+	REL_HYPERNYM: {"@", "@i"},
+	REL_HYPONYM:  {"~", "~i"},
+	REL_MERONYM:  {"%m", "%s", "%p"},
+	REL_HOLONYM:  {"#m", "#s", "#p"},
+	REL_ANTONYM:  {"!"},
+}
+
+func hasSymbol(symbols []string, symbol string) bool {
+	for _, s := range symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *WN) related(synset *Synset, relation string) []*Synset {
+	symbols := relationSymbols[relation]
+	result := make([]*Synset, 0)
+	seen := make(map[*Synset]bool)
+	for _, pointer := range synset.Pointer {
+		if !hasSymbol(symbols, pointer.Symbol) {
+			continue
+		}
+		target := this.wn.Synset[pointer.Synset]
+		if target != nil && !seen[target] {
+			seen[target] = true
+			result = append(result, target)
+		}
+	}
+	return result
+}
+
+// Related returns the synsets linked to the synset id by relation (one of the
+// REL_* constants), or nil if the synset or the relation are unknown.
+func (this *WN) Related(id string, relation string) []*WNSynset {
+	synset := this.synset(id)
+	if synset == nil || relationSymbols[relation] == nil {
+		return nil
+	}
+	result := make([]*WNSynset, 0)
+	for _, s := range this.related(synset, relation) {
+		result = append(result, this.toWNSynset(s))
+	}
+	return result
+}
+
+// Closure returns the transitive closure of relation from the synset id,
+// up to depth levels (0 means no limit), in breadth-first order.
+func (this *WN) Closure(id string, relation string, depth int) []*WNSynset {
+	synset := this.synset(id)
+	if synset == nil || relationSymbols[relation] == nil {
+		return nil
+	}
+	result := make([]*WNSynset, 0)
+	seen := map[*Synset]bool{synset: true}
+	level := []*Synset{synset}
+	for d := 1; len(level) > 0 && (depth == 0 || d <= depth); d++ {
+		next := make([]*Synset, 0)
+		for _, s := range level {
+			for _, r := range this.related(s, relation) {
+				if !seen[r] {
+					seen[r] = true
+					next = append(next, r)
+					result = append(result, this.toWNSynset(r))
+				}
+			}
+		}
+		level = next
+	}
+	return result
+}
+
+// RelatedWords returns the words of the synsets linked by relation to any
+// sense of lemma, without duplicates. Useful for query expansion.
+func (this *WN) RelatedWords(lemma string, pos string, relation string) []string {
+	words := make([]string, 0)
+	seen := map[string]bool{lemma: true}
+	for _, synset := range this.Lookup(lemma, pos) {
+		for _, r := range this.Related(synset.Id, relation) {
+			for _, w := range r.Words {
+				if !seen[w] {
+					seen[w] = true
+					words = append(words, w)
+				}
+			}
+		}
+	}
+	return words
+}
+
+// ancestors returns every hypernym of synset (including itself) with its
+// shortest distance.
+func (this *WN) ancestors(synset *Synset) map[*Synset]int {
+	dist := map[*Synset]int{synset: 0}
+	level := []*Synset{synset}
+	for d := 1; len(level) > 0; d++ {
+		next := make([]*Synset, 0)
+		for _, s := range level {
+			for _, h := range this.related(s, REL_HYPERNYM) {
+				if _, ok := dist[h]; !ok {
+					dist[h] = d
+					next = append(next, h)
+				}
+			}
+		}
+		level = next
+	}
+	return dist
+}
+
+// buildDepths computes the depth of every synset, the length of its shortest
+// hypernym path to a root counting both ends, and the deepest synset of every
+// part of speech.
+func (this *WN) buildDepths() {
+	this.depths = make(map[*Synset]int)
+	this.maxDepths = make(map[string]int)
+	for synset := range this.ids {
+		d := this.depthOf(synset, make(map[*Synset]bool))
+		if pos := shortPOS(synset.Pos); d > this.maxDepths[pos] {
+			this.maxDepths[pos] = d
+		}
+	}
+}
+
+// depthOf computes the depth of synset from the depths of its hypernyms.
+// visiting holds the synsets of the path being computed, to stop on cycles.
+func (this *WN) depthOf(synset *Synset, visiting map[*Synset]bool) int {
+	if d, ok := this.depths[synset]; ok {
+		return d
+	}
+	visiting[synset] = true
+	depth := 0
+	for _, h := range this.related(synset, REL_HYPERNYM) {
+		if visiting[h] {
+			continue
+		}
+		if d := this.depthOf(h, visiting) + 1; depth == 0 || d < depth {
+			depth = d
+		}
+	}
+	delete(visiting, synset)
+	if depth == 0 {
+		depth = 1
+	}
+	this.depths[synset] = depth
+	return depth
+}
+
+// depth is the length of the shortest hypernym path from synset to a root,
+// counting both ends.
+func (this *WN) depth(synset *Synset) int {
+	return this.depths[synset]
+}
+
+// maxDepth is the depth of the deepest synset of the given part of speech.
+func (this *WN) maxDepth(pos string) int {
+	return this.maxDepths[pos]
+}
+
+// subsumer returns the distances of both synsets to their lowest common
+// subsumer and its depth. When the synsets share no hypernym (e.g. verbs) a
+// virtual root above every hierarchy is used, with depth 0.
+func (this *WN) subsumer(s1 *Synset, s2 *Synset) (int, int, int) {
+	a1 := this.ancestors(s1)
+	a2 := this.ancestors(s2)
+
+	best := -1
+	d1, d2 := 0, 0
+	for s, dist1 := range a1 {
+		dist2, ok := a2[s]
+		if !ok {
+			continue
+		}
+		if depth := this.depth(s); depth > best || (depth == best && dist1+dist2 < d1+d2) {
+			best = depth
+			d1, d2 = dist1, dist2
+		}
+	}
+
+	if best == -1 {
+		return this.depth(s1), this.depth(s2), 0
+	}
+	return d1, d2, best
+}
+
+var (
+	ErrUnknownSynset  = errors.New("unknown synset")
+	ErrUnknownMeasure = errors.New("unknown similarity measure")
+	ErrPOSMismatch    = errors.New("synsets of different part of speech")
+)
+
+// Similarity computes a path based similarity (SIM_PATH, SIM_WUP or
+// SIM_LCH) between two synsets. It fails with ErrUnknownSynset when a synset
+// is unknown, ErrUnknownMeasure when the measure is, and ErrPOSMismatch when
+// SIM_LCH is asked for synsets of different POS.
+func (this *WN) Similarity(id1 string, id2 string, measure string) (float64, error) {
+	s1 := this.synset(id1)
+	s2 := this.synset(id2)
+	if s1 == nil || s2 == nil {
+		return 0, ErrUnknownSynset
+	}
+
+	d1, d2, depth := this.subsumer(s1, s2)
+	switch measure {
+	case SIM_PATH:
+		return 1.0 / float64(d1+d2+1), nil
+	case SIM_WUP:
+		if depth == 0 {
+			depth = 1
+			d1++
+			d2++
+		}
+		return 2.0 * float64(depth) / float64(d1+d2+2*depth), nil
+	case SIM_LCH:
+		pos := shortPOS(s1.Pos)
+		if pos != shortPOS(s2.Pos) {
+			return 0, ErrPOSMismatch
+		}
+		return -math.Log(float64(d1+d2+1) / float64(2*this.maxDepth(pos))), nil
+	}
+	return 0, ErrUnknownMeasure
+}
@@ -1,50 +1,41 @@
 package wordnet
 
 import (
+	"strings"
+
 	. "github.com/advancedlogic/go-freeling/models"
 	. "github.com/advancedlogic/go-freeling/terminal"
 	. "github.com/fluhus/gostuff/nlp/wordnet"
 )
 
 type WN struct {
-	wn *WordNet
+	wn         *WordNet
+	ids        map[*Synset]string
+	offsets    map[string]*Synset
+	depths     map[*Synset]int
+	maxDepths  map[string]int
+	exceptions map[string]map[string][]string
 }
 
-type partOfSpeech struct {
-	short string
-	long  string
+// WNSynset is the public view of a WordNet synset. Id follows the FreeLing
+// sense notation (offset-pos), e.g. 02084071-n.
+type WNSynset struct {
+	Id     string   `json:"id"`
+	Pos    string   `json:"pos"`
+	Offset string   `json:"offset"`
+	Words  []string `json:"words"`
+	Gloss  string   `json:"gloss"`
 }
 
-func getPOS(p string) (pos *partOfSpeech) {
-
-	pos = new(partOfSpeech)
-
-	switch p {
-
-	case "JJ", "JJR", "JJS":
-		pos.short = "a" //adjective
-		pos.long = "adjective"
-		break
-
-	case "NNS", "NN", "NNP", "NP00000", "NP", "NP00G00", "NP00O00", "NP00V00", "NP00SP0", "NNPS":
-		pos.short = "n" //noun
-		pos.long = "noun"
-		break
+// searchPOS is the order in which the synsets found by Search are returned,
+// adjective satellites after adjectives.
+var searchPOS = []string{"n", "v", "a", "s", "r"}
 
-	case "RB", "RBR", "RBS", "WRB":
-		pos.short = "r" //adverb
-		pos.long = "adverb"
-		break
-
-	case "MD", "VBG", "VB", "VBN", "VBD", "VBP", "VBZ":
-		pos.short = "v" //verb
-		pos.long = "verb"
-		break
-
-	default:
-		return nil
-	}
-	return pos
+var longPOS = map[string]string{
+	"n": "noun",
+	"v": "verb",
+	"a": "adjective",
+	"r": "adverb",
 }
 
 func NewWordNet() *WN {
@@ -57,29 +48,129 @@ func NewWordNet() *WN {
 		Outputln("There was an error during parsing WordNet database")
 	} else {
 		instance.wn = wn
+		instance.exceptions = loadExceptions("./data/dict")
+		instance.buildIndex()
+		instance.buildDepths()
 	}
 
 	return instance
 
 }
 
-func (this *WN) Annotate(word string, pos string) []*Annotation {
+// buildIndex maps every synset to its FreeLing style id, so synsets returned
+// by Search can be referenced and looked up by offset.
+func (this *WN) buildIndex() {
+	this.ids = make(map[*Synset]string)
+	this.offsets = make(map[string]*Synset)
+	for key, synset := range this.wn.Synset {
+		offset := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, key)
+		for len(offset) < 8 {
+			offset = "0" + offset
+		}
+		id := offset + "-" + shortPOS(synset.Pos)
+		this.ids[synset] = id
+		this.offsets[id] = synset
+	}
+}
+
+// shortPOS folds adjective satellites into adjectives.
+func shortPOS(pos string) string {
+	if pos == "s" {
+		return "a"
+	}
+	return pos
+}
+
+func (this *WN) toWNSynset(synset *Synset) *WNSynset {
+	id := this.ids[synset]
+	return &WNSynset{
+		Id:     id,
+		Pos:    shortPOS(synset.Pos),
+		Offset: id[0:strings.Index(id, "-")],
+		Words:  synset.Word,
+		Gloss:  synset.Gloss,
+	}
+}
+
+// Lookup returns the synsets containing lemma. pos is a WordNet part of
+// speech (n, v, a, r); an empty pos returns the synsets of every category.
+func (this *WN) Lookup(lemma string, pos string) []*WNSynset {
 	if this.wn == nil {
 		return nil
 	}
 
-	wnPOS := getPOS(pos)
+	result := make([]*WNSynset, 0)
+	found := this.wn.Search(strings.ToLower(lemma))
+	for _, p := range searchPOS {
+		if pos != "" && shortPOS(p) != pos {
+			continue
+		}
+		for _, synset := range found[p] {
+			result = append(result, this.toWNSynset(synset))
+		}
+	}
+	return result
+}
 
-	if wnPOS == nil {
+// SynsetById returns the synset with the given id (e.g. 02084071-n), or nil.
+func (this *WN) SynsetById(id string) *WNSynset {
+	synset := this.synset(id)
+	if synset == nil {
 		return nil
 	}
+	return this.toWNSynset(synset)
+}
 
-	result := this.wn.Search(word)[wnPOS.short]
+// SynsetByOffset returns the synset with the given part of speech and
+// database offset, or nil.
+func (this *WN) SynsetByOffset(pos string, offset string) *WNSynset {
+	for len(offset) < 8 {
+		offset = "0" + offset
+	}
+	return this.SynsetById(offset + "-" + shortPOS(pos))
+}
 
-	annotation := []*Annotation{}
+func (this *WN) synset(id string) *Synset {
+	if this.wn == nil {
+		return nil
+	}
+	return this.offsets[id]
+}
 
-	for _, synset := range result {
-		annotation = append(annotation, &Annotation{wnPOS.long, synset.Word, synset.Gloss})
+// Annotate returns the synsets of lemma for the WordNet part of speech pos
+// (n, v, a, r). When the lemma is unknown to WordNet, the base forms found by
+// Morphy for the surface form are used instead.
+func (this *WN) Annotate(lemma string, form string, pos string) []*Annotation {
+	if this.wn == nil || longPOS[pos] == "" {
+		return nil
+	}
+
+	lemmas := []string{strings.ToLower(lemma)}
+	if !this.exists(lemmas[0], pos) {
+		lemmas = this.Morphy(form, pos)
+	}
+
+	annotation := []*Annotation{}
+	seen := make(map[*Synset]bool)
+	for _, l := range lemmas {
+		found := this.wn.Search(l)
+		for _, p := range searchPOS {
+			if shortPOS(p) != pos {
+				continue
+			}
+			for _, synset := range found[p] {
+				if seen[synset] {
+					continue
+				}
+				seen[synset] = true
+				annotation = append(annotation, &Annotation{longPOS[pos], synset.Word, synset.Gloss})
+			}
+		}
 	}
 
 	return annotation
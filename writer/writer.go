@@ -0,0 +1,196 @@
+package writer
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/advancedlogic/go-freeling/models"
+)
+
+// Output levels of the FreeLing analyze command.
+const (
+	LEVEL_TOKEN    = "token"
+	LEVEL_SPLITTED = "splitted"
+	LEVEL_MORFO    = "morfo"
+	LEVEL_TAGGED   = "tagged"
+	LEVEL_SHALLOW  = "shallow"
+	LEVEL_SENSE    = "sense"
+)
+
+var levels = map[string]bool{
+	LEVEL_TOKEN:    true,
+	LEVEL_SPLITTED: true,
+	LEVEL_MORFO:    true,
+	LEVEL_TAGGED:   true,
+	LEVEL_SHALLOW:  true,
+	LEVEL_SENSE:    true,
+}
+
+func IsLevel(level string) bool {
+	return levels[level]
+}
+
+// Writer prints analyzed documents in the column formats of FreeLing's
+// analyze command, so results can be diffed against the C++ FreeLing.
+type Writer struct {
+	level  string
+	senses bool
+}
+
+func NewWriter(level string) *Writer {
+	return &Writer{
+		level:  level,
+		senses: level == LEVEL_SENSE,
+	}
+}
+
+// SetSenses adds the sense column to tagged and shallow output, as FreeLing
+// does when sense annotation is active.
+func (this *Writer) SetSenses(senses bool) {
+	this.senses = senses
+}
+
+func (this *Writer) Write(out io.Writer, document *models.DocumentEntity) error {
+	w := bufio.NewWriter(out)
+	if document.Sentences() == nil {
+		return w.Flush()
+	}
+
+	for s := document.Sentences().Front(); s != nil; s = s.Next() {
+		this.writeSentence(w, s.Value.(*models.SentenceEntity))
+	}
+	return w.Flush()
+}
+
+// WriteSentence writes a single sentence, for sentences analyzed one at a
+// time.
+func (this *Writer) WriteSentence(out io.Writer, se *models.SentenceEntity) error {
+	w := bufio.NewWriter(out)
+	this.writeSentence(w, se)
+	return w.Flush()
+}
+
+func (this *Writer) writeSentence(w *bufio.Writer, se *models.SentenceEntity) {
+	switch this.level {
+	case LEVEL_TOKEN:
+		this.writeTokens(w, se)
+		return
+	case LEVEL_SPLITTED:
+		this.writeTokens(w, se)
+	case LEVEL_MORFO:
+		this.writeMorfo(w, se)
+	case LEVEL_SHALLOW:
+		if se.GetTree() != nil {
+			this.writeTree(w, tokens(se), se.GetTree(), 0)
+		} else {
+			this.writeTagged(w, se)
+		}
+	default:
+		this.writeTagged(w, se)
+	}
+	w.WriteString("\n")
+}
+
+func tokens(se *models.SentenceEntity) []*models.TokenEntity {
+	result := make([]*models.TokenEntity, 0, se.Tokens().Len())
+	for t := se.Tokens().Front(); t != nil; t = t.Next() {
+		result = append(result, t.Value.(*models.TokenEntity))
+	}
+	return result
+}
+
+// writeTokens prints the tokens as they came out of the tokenizer: the
+// words of a multiword separately, and split contractions once.
+func (this *Writer) writeTokens(w *bufio.Writer, se *models.SentenceEntity) {
+	ts := tokens(se)
+	for i := 0; i < len(ts); i++ {
+		te := ts[i]
+		if components := te.GetComponents(); len(components) > 0 {
+			for _, c := range components {
+				w.WriteString(c.GetBase() + "\n")
+			}
+			continue
+		}
+		j := i
+		for j+1 < len(ts) && te.GetSpanFinish() > te.GetSpanStart() && ts[j+1].GetSpanStart() == te.GetSpanStart() && ts[j+1].GetSpanFinish() == te.GetSpanFinish() {
+			j++
+		}
+		if j > i {
+			w.WriteString(se.Surface(te) + "\n")
+			i = j
+			continue
+		}
+		w.WriteString(te.GetBase() + "\n")
+	}
+}
+
+func (this *Writer) writeMorfo(w *bufio.Writer, se *models.SentenceEntity) {
+	for _, te := range tokens(se) {
+		line := te.GetBase()
+		for _, a := range te.GetAnalyses() {
+			line += " " + a.Lemma + " " + a.Tag + " " + prob(a.Prob)
+		}
+		w.WriteString(line + "\n")
+	}
+}
+
+func (this *Writer) writeTagged(w *bufio.Writer, se *models.SentenceEntity) {
+	for _, te := range tokens(se) {
+		analyses := te.GetAnalyses()
+		if len(analyses) == 0 {
+			w.WriteString(te.GetBase() + " " + te.GetLemma() + " " + te.GetPos() + " " + prob(te.GetProb()) + this.outputSenses(te) + "\n")
+			continue
+		}
+		for _, a := range analyses {
+			if a.Selected {
+				w.WriteString(te.GetBase() + " " + a.Lemma + " " + a.Tag + " " + prob(a.Prob) + this.outputSenses(te) + "\n")
+			}
+		}
+	}
+}
+
+func (this *Writer) writeTree(w *bufio.Writer, ts []*models.TokenEntity, node *models.ParseTreeEntity, depth int) {
+	w.WriteString(strings.Repeat(" ", depth*2))
+	if node.Head {
+		w.WriteString("+")
+	}
+	if len(node.Children) == 0 {
+		if node.Word < 0 || node.Word >= len(ts) {
+			w.WriteString("\n")
+			return
+		}
+		te := ts[node.Word]
+		w.WriteString("(" + te.GetBase() + " " + te.GetLemma() + " " + te.GetPos() + this.outputSenses(te) + ")\n")
+		return
+	}
+
+	w.WriteString(node.Label + "_[\n")
+	for _, child := range node.Children {
+		this.writeTree(w, ts, child, depth+1)
+	}
+	w.WriteString(strings.Repeat(" ", depth*2) + "]\n")
+}
+
+// outputSenses returns the sense column (id:rank/id:rank, or - when the
+// word has no senses) when senses are written.
+func (this *Writer) outputSenses(te *models.TokenEntity) string {
+	if !this.senses {
+		return ""
+	}
+	senses := te.GetSenses()
+	if len(senses) == 0 {
+		return " -"
+	}
+	items := make([]string, 0, len(senses))
+	for _, s := range senses {
+		items = append(items, s.Id+":"+prob(s.Rank))
+	}
+	return " " + strings.Join(items, "/")
+}
+
+// prob formats a number like the default C++ ostream does.
+func prob(p float64) string {
+	return strconv.FormatFloat(p, 'g', 6, 64)
+}